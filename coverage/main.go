@@ -0,0 +1,78 @@
+// coverage main.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/olesho/descry2/parser"
+)
+
+// bucket of tester storage documents are kept in, keyed by URL
+const bodyBucket = "body"
+
+// Applies patterns to documents captured by tester and prints which patterns matched which URLs
+// and fill rates of fields (see parser/coverage.go), like:
+//
+//	coverage -d proxy/patterns -db tester/storage.db -json
+func main() {
+	patternsDir := flag.String("d", os.Getenv("PATTERNS_DIR"), "Patterns directory")
+	dbFileName := flag.String("db", "storage.db", "Tester storage file")
+	mime := flag.String("mime", "html", "Type of stored documents: html, json or xml")
+	asJson := flag.Bool("json", false, "Print report as JSON")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "", log.Lshortfile)
+
+	// default if no env nor flag set
+	if len(*patternsDir) == 0 {
+		*patternsDir = "patterns"
+	}
+	patterns := parser.NewPatterns(logger)
+	if err := patterns.LoadTree(*patternsDir); err != nil {
+		logger.Fatal(err)
+	}
+
+	// fails rather than waits if tester is running
+	db, err := bolt.Open(*dbFileName, 0600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		logger.Fatal("Error opening ", *dbFileName, ": ", err)
+	}
+	defer db.Close()
+
+	coverage := parser.NewCoverage(patterns.Tree)
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bodyBucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			doc, err := parser.ParseDocument(*mime, bytes.NewReader(v))
+			if err != nil {
+				logger.Println("Error parsing ", string(k), ": ", err)
+				return nil
+			}
+			coverage.Add(&parser.Context{URL: string(k), Mime: *mime}, doc)
+			return nil
+		})
+	})
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	if *asJson {
+		data, err := json.MarshalIndent(coverage, "", "\t")
+		if err != nil {
+			logger.Fatal("Error marshalling to JSON: ", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Print(coverage)
+}
@@ -0,0 +1,65 @@
+// generator main.go
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/olesho/descry2/parser"
+)
+
+// Generates Go types and JSON Schema documents matching output of patterns, like:
+//
+//	generator -d patterns -o types.go -package jobs -schemas schemas
+//
+// Go source is written to stdout if neither -o nor -schemas is set
+func main() {
+	patternsDir := flag.String("d", os.Getenv("PATTERNS_DIR"), "Patterns directory")
+	goFile := flag.String("o", "", "Go types output file")
+	pkg := flag.String("package", "patterns", "Package of Go types")
+	schemasDir := flag.String("schemas", "", "JSON Schema output directory, a file per pattern")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "", log.Lshortfile)
+
+	// default if no env nor flag set
+	if len(*patternsDir) == 0 {
+		*patternsDir = "patterns"
+	}
+	patterns := parser.NewPatterns(logger)
+	if err := patterns.LoadTree(*patternsDir); err != nil {
+		logger.Fatal(err)
+	}
+
+	if len(*goFile) > 0 || len(*schemasDir) == 0 {
+		src, err := patterns.Tree.GoTypes(*pkg)
+		if err != nil {
+			logger.Fatal("Error generating Go types: ", err)
+		}
+		if len(*goFile) == 0 {
+			os.Stdout.Write(src)
+		} else if err = ioutil.WriteFile(*goFile, src, 0666); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	if len(*schemasDir) > 0 {
+		for path, schema := range patterns.Tree.JsonSchemas() {
+			data, err := json.MarshalIndent(schema, "", "\t")
+			if err != nil {
+				logger.Fatal("Error marshalling JSON Schema: ", err)
+			}
+			fileName := filepath.Join(*schemasDir, filepath.FromSlash(path)+".schema.json")
+			if err = os.MkdirAll(filepath.Dir(fileName), 0777); err != nil {
+				logger.Fatal(err)
+			}
+			if err = ioutil.WriteFile(fileName, data, 0666); err != nil {
+				logger.Fatal(err)
+			}
+		}
+	}
+}
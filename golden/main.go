@@ -0,0 +1,71 @@
+// golden main.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/olesho/descry2/parser"
+)
+
+// Runs golden files tests of patterns (see parser/golden.go) and prints differences of failed cases, like:
+//
+//	FAIL startupgigs/stackoverflow.com/Item.xml _golden/Item.xml/search.html
+//		Item[2].Title: "Go developer" expected, got "Designer"
+//
+// exit status is 1 if any case failed; with -update golden files of failed cases are rewritten instead
+func main() {
+	patternsDir := flag.String("d", os.Getenv("PATTERNS_DIR"), "Patterns directory")
+	update := flag.Bool("update", false, "Rewrite golden files with current outputs")
+	run := flag.String("run", "", "Only run cases whose pattern path matches regex")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "", log.Lshortfile)
+
+	// default if no env nor flag set
+	if len(*patternsDir) == 0 {
+		*patternsDir = "patterns"
+	}
+	filter, err := regexp.Compile(*run)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	patterns := parser.NewPatterns(logger)
+	if err := patterns.LoadTree(*patternsDir); err != nil {
+		logger.Fatal(err)
+	}
+
+	total, failed := 0, 0
+	for _, res := range patterns.RunGolden(*patternsDir, filter, *update) {
+		total++
+		file, err := filepath.Rel(filepath.Join(*patternsDir, filepath.Dir(res.Pattern)), res.File)
+		if err != nil {
+			file = res.File
+		}
+
+		switch {
+		case res.Err != nil:
+			failed++
+			fmt.Println("FAIL", res.Pattern, file)
+			fmt.Println("\t" + res.Err.Error())
+		case res.Updated:
+			fmt.Println("updated", res.Pattern, file)
+		case res.Failed():
+			failed++
+			fmt.Println("FAIL", res.Pattern, file)
+			for _, diff := range res.Diffs {
+				fmt.Println("\t" + diff)
+			}
+		default:
+			fmt.Println("ok", res.Pattern, file)
+		}
+	}
+	fmt.Println(total, "cases,", failed, "failed")
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
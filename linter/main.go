@@ -0,0 +1,33 @@
+// linter main.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/olesho/descry2/parser"
+)
+
+// Checks patterns directory and prints issues found, one per line, like:
+//
+//	patterns/startupgigs/f6s.com/Body.xml:12: Field Title has no Path
+//
+// exit status is 1 if there are issues
+func main() {
+	patternsDir := flag.String("d", os.Getenv("PATTERNS_DIR"), "Patterns directory")
+	flag.Parse()
+
+	// default if no env nor flag set
+	if len(*patternsDir) == 0 {
+		*patternsDir = "patterns"
+	}
+
+	issues := parser.Lint(*patternsDir)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	if len(issues) > 0 {
+		os.Exit(1)
+	}
+}
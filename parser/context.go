@@ -0,0 +1,126 @@
+// patterns
+package parser
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/antchfx/xquery/html"
+	"golang.org/x/net/html"
+)
+
+// Context of the document being parsed: where it came from and when
+type Context struct {
+	// request URL
+	URL string
+
+	// response date; used as anchor for relative dates like "3 days ago"
+	Date time.Time
+
+	// base URL declared in document: <base href="...">
+	Base string
+
+	// document type: "html" (default), "json" or "xml"
+	Mime string
+
+	// named groups of URL Include regex of the pattern being applied
+	Captures map[string]string
+
+	// values of fields retrieved so far, available as XPath variables; see variables.go
+	vars map[string]string
+
+	// reason document failed due to missing required="fail-document" field; see defaults.go
+	failure *string
+
+	// set if value of field being retrieved was nulled by oninvalid="null" policy; see validate.go
+	nulled *bool
+}
+
+// Creates context from response, taking request URL, "Content-Type" and "Date" headers
+func NewResponseContext(resp *http.Response) *Context {
+	ctx := &Context{Mime: MimeType(resp.Header.Get("Content-Type"))}
+	if resp.Request != nil && resp.Request.URL != nil {
+		ctx.URL = resp.Request.URL.String()
+	}
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		ctx.Date = date
+	}
+	return ctx
+}
+
+// returns current time unless response date is known
+func (ctx *Context) now() time.Time {
+	if ctx == nil || ctx.Date.IsZero() {
+		return time.Now()
+	}
+	return ctx.Date
+}
+
+func (ctx *Context) mime() string {
+	if ctx == nil || ctx.Mime == "" {
+		return "html"
+	}
+	return ctx.Mime
+}
+
+func (ctx *Context) url() string {
+	if ctx == nil {
+		return ""
+	}
+	return ctx.URL
+}
+
+// returns URL relative links are resolved against; nil if unknown
+func (ctx *Context) baseUrl() (*url.URL, error) {
+	if ctx == nil || (ctx.URL == "" && ctx.Base == "") {
+		return nil, nil
+	}
+
+	base, err := url.Parse(ctx.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx.Base != "" {
+		declared, err := url.Parse(ctx.Base)
+		if err != nil {
+			return nil, err
+		}
+		base = base.ResolveReference(declared)
+	}
+	return base, nil
+}
+
+// returns copy of context with URL captures of pattern
+func (ctx *Context) withCaptures(captures map[string]string) *Context {
+	c := &Context{}
+	if ctx != nil {
+		*c = *ctx
+	}
+	c.Captures = captures
+	return c
+}
+
+func (ctx *Context) capture(name string) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	val, ok := ctx.Captures[name]
+	return val, ok
+}
+
+// returns copy of context with base URL taken from document
+func (ctx *Context) withDocument(doc *html.Node) *Context {
+	c := &Context{}
+	if ctx != nil {
+		*c = *ctx
+	}
+	if c.Base == "" {
+		if base := htmlquery.FindOne(doc, "//base[@href]"); base != nil {
+			c.Base = strings.TrimSpace(htmlquery.SelectAttr(base, "href"))
+		}
+	}
+	return c
+}
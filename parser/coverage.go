@@ -0,0 +1,204 @@
+// patterns
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// Coverage of pattern tree over corpus of documents: which patterns matched which URLs and how often every field
+// is populated. Fill rate of a field is share of structs (documents for root fields) the field has value in;
+// null, empty string, empty list and left out (omitempty) values don't count:
+//
+//	coverage := NewCoverage(patterns.Tree)
+//	for url, content := range corpus {
+//		doc, _ := ParseDocument("html", bytes.NewReader(content))
+//		coverage.Add(&Context{URL: url}, doc)
+//	}
+//	fmt.Print(coverage)
+type Coverage struct {
+	Documents int `json:"documents"`
+
+	// URLs of documents no pattern retrieved anything from
+	Unmatched []string `json:"unmatched,omitempty"`
+
+	// every pattern of the tree, ordered by path
+	Patterns []*PatternCoverage `json:"patterns"`
+
+	tree *PatternNode
+}
+
+type PatternCoverage struct {
+	// pattern path, like: startupgigs/stackoverflow.com/Item.xml
+	Pattern string `json:"pattern"`
+
+	// number of documents of pattern mime and URL fitting its rules
+	Applied int `json:"applied"`
+
+	// URLs of documents pattern retrieved data from
+	Matched []string `json:"matched,omitempty"`
+
+	Field *FieldCoverage `json:"field"`
+
+	pattern *CompiledMap
+}
+
+type FieldCoverage struct {
+	Field string `json:"field"`
+
+	Optional bool `json:"optional,omitempty"`
+
+	// branch of struct Switch field belongs to
+	When string `json:"when,omitempty"`
+
+	// number of structs (documents for root fields) field could have value in
+	Total int `json:"total"`
+
+	// number of them field has value in
+	Filled int `json:"filled"`
+
+	// Filled/Total
+	FillRate float64 `json:"fill_rate"`
+
+	// sub-fields of struct fields
+	Fields []*FieldCoverage `json:"fields,omitempty"`
+
+	// title branch names of sub-fields are stored to
+	variantTitle string
+}
+
+func NewCoverage(pn *PatternNode) *Coverage {
+	c := &Coverage{tree: pn, Patterns: make([]*PatternCoverage, 0)}
+	pn.walk("", func(path string, m *CompiledMap) {
+		c.Patterns = append(c.Patterns, &PatternCoverage{Pattern: path, Field: newFieldCoverage(m.field, ""), pattern: m})
+	})
+	return c
+}
+
+func newFieldCoverage(f *CompiledField, when string) *FieldCoverage {
+	c := &FieldCoverage{Field: f.title, Optional: f.optional, When: when, variantTitle: f.variantTitle}
+	for _, child := range f.field {
+		c.Fields = append(c.Fields, newFieldCoverage(child, ""))
+	}
+	for _, v := range f.variants {
+		for _, child := range v.field {
+			c.Fields = append(c.Fields, newFieldCoverage(child, v.name))
+		}
+	}
+	if f.grouped {
+		// groups without sub-fields are strings
+		for _, group := range f.data.Groups() {
+			if c.child(group) == nil {
+				c.Fields = append(c.Fields, &FieldCoverage{Field: group})
+			}
+		}
+	}
+	return c
+}
+
+func (c *FieldCoverage) child(title string) *FieldCoverage {
+	for _, child := range c.Fields {
+		if child.Field == title {
+			return child
+		}
+	}
+	return nil
+}
+
+// Applies patterns to document and counts their outputs
+func (c *Coverage) Add(ctx *Context, doc *Document) {
+	c.Documents++
+	data := c.tree.ApplyDocument(ctx, doc)
+	if data == nil {
+		c.Unmatched = append(c.Unmatched, ctx.url())
+	}
+
+	for _, p := range c.Patterns {
+		if p.pattern.mime != doc.Mime || ctx.url() != "" && !p.pattern.url.Test([]byte(ctx.url())) {
+			continue
+		}
+		p.Applied++
+		if found, ok := lookupPath(data, p.Pattern); ok {
+			p.Matched = append(p.Matched, ctx.url())
+			p.Field.add(found.(map[string]interface{})[p.Field.Field])
+		}
+	}
+}
+
+// counts value of field found in struct
+func (c *FieldCoverage) add(val interface{}) {
+	c.Total++
+	if populated(val) {
+		c.Filled++
+		c.addItems(val)
+	}
+	c.FillRate = float64(c.Filled) / float64(c.Total)
+}
+
+// counts sub-fields of every struct of value
+func (c *FieldCoverage) addItems(val interface{}) {
+	switch val := val.(type) {
+	case []interface{}:
+		for _, item := range val {
+			c.addItems(item)
+		}
+	case map[string]interface{}:
+		// structured data maps have no sub-fields
+		for _, child := range c.Fields {
+			if child.When == "" || val[c.variantTitle] == child.When {
+				child.add(val[child.Field])
+			}
+		}
+	}
+}
+
+// false for null, empty string, list and map
+func populated(val interface{}) bool {
+	switch val := val.(type) {
+	case nil:
+		return false
+	case string:
+		return val != ""
+	case []interface{}:
+		return len(val) > 0
+	case map[string]interface{}:
+		return len(val) > 0
+	}
+	return true
+}
+
+// Human-readable report: patterns with number of documents they applied to and matched, fields with fill rates
+func (c *Coverage) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "documents: %d, unmatched: %d\n", c.Documents, len(c.Unmatched))
+	for _, p := range c.Patterns {
+		fmt.Fprintf(&buf, "\n%s: applied %d, matched %d\n", p.Pattern, p.Applied, len(p.Matched))
+		if len(p.Matched) == 0 {
+			continue
+		}
+		w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+		p.Field.write(w, 1)
+		w.Flush()
+	}
+	return buf.String()
+}
+
+func (c *FieldCoverage) write(w *tabwriter.Writer, depth int) {
+	title := strings.Repeat("  ", depth) + c.Field
+	if c.When != "" {
+		title += " (" + c.When + ")"
+	}
+	if c.Optional {
+		title += " (optional)"
+	}
+	rate := fmt.Sprintf("%.1f%%", c.FillRate*100)
+	if c.Total > 0 && c.Filled == 0 {
+		rate = "never populated"
+	}
+	fmt.Fprintf(w, "%s\t%d/%d\t%s\n", title, c.Filled, c.Total, rate)
+	for _, child := range c.Fields {
+		child.write(w, depth+1)
+	}
+}
@@ -0,0 +1,75 @@
+// patterns
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var coveragePatternStr = `
+<Pattern mime="html">
+	<URL><Include>^https://example\.com/jobs</Include></URL>
+	<Field title="Item" type="[]struct">
+		<Path>//li</Path>
+		<Field title="Title" type="string"><Path>.//h2</Path></Field>
+		<Field title="Salary" type="int" optional="true"><Path>.//span[@class='salary']</Path></Field>
+		<Field title="Remote" type="bool" optional="true"><Path>.//span[@class='remote']</Path></Field>
+		<Switch title="Kind">
+			<When name="sponsored">
+				<XData><Include>self::*[@class='ad']</Include></XData>
+				<Field title="Sponsor" type="string"><Path>.//b</Path></Field>
+			</When>
+			<When name="regular"/>
+		</Switch>
+	</Field>
+</Pattern>
+`
+
+func TestCoverage(t *testing.T) {
+	p := NewPatterns(nil)
+	assert.NoError(t, p.LoadXml(p.Tree, []byte(coveragePatternStr), "Item.xml"))
+
+	coverage := NewCoverage(p.Tree)
+	for url, content := range map[string]string{
+		"https://example.com/jobs?page=1": `<ul>
+			<li><h2>Go developer</h2><span class="salary">100</span></li>
+			<li><h2>Designer</h2></li>
+			<li class="ad"><h2>Sponsored job</h2><b>Acme</b></li>
+		</ul>`,
+		"https://example.com/jobs?page=2": `<ul><li><h2>Tester</h2></li></ul>`,
+		"https://example.com/about":       `<ul><li><h2>About</h2></li></ul>`,
+	} {
+		doc, err := ParseDocument("html", strings.NewReader(content))
+		assert.NoError(t, err)
+		coverage.Add(&Context{URL: url}, doc)
+	}
+
+	assert.Equal(t, 3, coverage.Documents)
+	assert.Equal(t, []string{"https://example.com/about"}, coverage.Unmatched)
+	item := coverage.Patterns[0]
+	assert.Equal(t, "Item.xml", item.Pattern)
+	assert.Equal(t, 2, item.Applied)
+	assert.Len(t, item.Matched, 2)
+
+	assert.Equal(t, 2, item.Field.Filled)
+	fields := make(map[string]*FieldCoverage)
+	for _, f := range item.Field.Fields {
+		fields[f.Field] = f
+	}
+	assert.Equal(t, 4, fields["Title"].Total)
+	assert.Equal(t, 1.0, fields["Title"].FillRate)
+	assert.Equal(t, 0.25, fields["Salary"].FillRate)
+	assert.Equal(t, 0, fields["Remote"].Filled)
+	// branch fields are counted for items of the branch only
+	assert.Equal(t, "sponsored", fields["Sponsor"].When)
+	assert.Equal(t, 1, fields["Sponsor"].Total)
+	assert.Equal(t, 1, fields["Sponsor"].Filled)
+
+	report := coverage.String()
+	assert.Contains(t, report, "documents: 3, unmatched: 1\n")
+	assert.Contains(t, report, "Item.xml: applied 2, matched 2\n")
+	assert.Regexp(t, `Salary \(optional\) +1/4 +25\.0%`, report)
+	assert.Regexp(t, `Remote \(optional\) +0/4 +never populated`, report)
+}
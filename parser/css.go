@@ -0,0 +1,389 @@
+// patterns
+package parser
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// prefix of CSS selectors in paths, like: css:div.item > a::attr(href)
+const cssPrefix = "css:"
+
+// Translates CSS selector to XPath expression relative to context node.
+// Supported:
+//
+//	tag, *, #id, .class
+//	[attr], [attr=val], [attr~=val], [attr^=val], [attr$=val], [attr*=val], [attr|=val]
+//	descendant (space), child (>), adjacent sibling (+) and general sibling (~) combinators
+//	:first-child, :last-child, :only-child, :nth-child(n), :empty, :not(simple), :contains(text)
+//	selector groups: a, b
+//	pseudo-elements: ::text (text nodes), ::attr(name) (attribute value)
+func CssToXpath(selector string) (string, error) {
+	groups, err := splitTopLevel(selector, ',')
+	if err != nil {
+		return "", err
+	}
+
+	paths := make([]string, 0, len(groups))
+	for _, group := range groups {
+		p := &cssParser{s: strings.TrimSpace(group)}
+		path, err := p.selector()
+		if err != nil {
+			return "", errors.New(err.Error() + "\n CSS selector: " + selector)
+		}
+		paths = append(paths, path)
+	}
+	return strings.Join(paths, " | "), nil
+}
+
+type cssParser struct {
+	s   string
+	pos int
+}
+
+func (p *cssParser) selector() (string, error) {
+	if p.s == "" {
+		return "", errors.New("Empty selector")
+	}
+
+	var path strings.Builder
+	axis := ".//"
+	for {
+		step, err := p.compound()
+		if err != nil {
+			return "", err
+		}
+		path.WriteString(axis + step.String())
+
+		// pseudo-elements finish selector
+		if p.consume("::") {
+			pseudo := p.ident()
+			switch {
+			case pseudo == "text":
+				path.WriteString("/text()")
+			case pseudo == "attr" && p.consume("("):
+				name := strings.TrimSpace(p.until(')'))
+				if !p.consume(")") || name == "" {
+					return "", errors.New("Bad ::attr() pseudo-element")
+				}
+				path.WriteString("/@" + name)
+			default:
+				return "", errors.New("Unsupported pseudo-element ::" + pseudo)
+			}
+			p.skipSpaces()
+			if !p.done() {
+				return "", errors.New("Pseudo-element should be the last one")
+			}
+			return path.String(), nil
+		}
+
+		spaces := p.skipSpaces()
+		if p.done() {
+			return path.String(), nil
+		}
+
+		switch {
+		case p.consume(">"):
+			axis = "/"
+		case p.consume("+"):
+			// only the nearest sibling element
+			axis = "/following-sibling::*[1]/self::"
+		case p.consume("~"):
+			axis = "/following-sibling::"
+		case spaces:
+			axis = "//"
+		default:
+			return "", errors.New("Unexpected '" + p.s[p.pos:] + "'")
+		}
+		p.skipSpaces()
+	}
+}
+
+// compound selector step: tag with predicates
+type cssStep struct {
+	tag        string
+	predicates []string
+}
+
+func (s *cssStep) String() string {
+	res := s.tag
+	for _, pred := range s.predicates {
+		res += "[" + pred + "]"
+	}
+	return res
+}
+
+func (s *cssStep) condition() string {
+	conds := make([]string, 0)
+	if s.tag != "*" {
+		conds = append(conds, "self::"+s.tag)
+	}
+	conds = append(conds, s.predicates...)
+	if len(conds) == 0 {
+		return "true()"
+	}
+	return strings.Join(conds, " and ")
+}
+
+func (p *cssParser) compound() (*cssStep, error) {
+	start := p.pos
+	step := &cssStep{tag: "*"}
+	if !p.consume("*") {
+		if name := p.ident(); name != "" {
+			step.tag = name
+		}
+	}
+
+	for !p.done() {
+		switch {
+		case p.consume("#"):
+			id := p.ident()
+			if id == "" {
+				return nil, errors.New("Empty id selector")
+			}
+			step.predicates = append(step.predicates, "@id="+xpathLiteral(id))
+		case p.consume("."):
+			class := p.ident()
+			if class == "" {
+				return nil, errors.New("Empty class selector")
+			}
+			step.predicates = append(step.predicates, containsWord("@class", class))
+		case p.consume("["):
+			pred, err := p.attribute()
+			if err != nil {
+				return nil, err
+			}
+			step.predicates = append(step.predicates, pred)
+		case strings.HasPrefix(p.s[p.pos:], "::"):
+			return step, nil
+		case p.consume(":"):
+			pred, err := p.pseudoClass()
+			if err != nil {
+				return nil, err
+			}
+			step.predicates = append(step.predicates, pred)
+		default:
+			if p.pos == start {
+				return nil, errors.New("Unexpected '" + p.s[p.pos:] + "'")
+			}
+			return step, nil
+		}
+	}
+	if p.pos == start {
+		return nil, errors.New("Empty selector")
+	}
+	return step, nil
+}
+
+func (p *cssParser) attribute() (string, error) {
+	p.skipSpaces()
+	name := p.ident()
+	if name == "" {
+		return "", errors.New("Empty attribute name")
+	}
+	attr := "@" + name
+	p.skipSpaces()
+
+	if p.consume("]") {
+		return attr, nil
+	}
+
+	op := ""
+	for _, o := range []string{"~=", "^=", "$=", "*=", "|=", "="} {
+		if p.consume(o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		return "", errors.New("Bad attribute selector")
+	}
+
+	p.skipSpaces()
+	val, err := p.value()
+	if err != nil {
+		return "", err
+	}
+	p.skipSpaces()
+	if !p.consume("]") {
+		return "", errors.New("Missing ']'")
+	}
+
+	lit := xpathLiteral(val)
+	switch op {
+	case "=":
+		return attr + "=" + lit, nil
+	case "~=":
+		return containsWord(attr, val), nil
+	case "^=":
+		return "starts-with(" + attr + ", " + lit + ")", nil
+	case "$=":
+		return "substring(" + attr + ", string-length(" + attr + ") - " + strconv.Itoa(utf8.RuneCountInString(val)-1) + ") = " + lit, nil
+	case "*=":
+		return "contains(" + attr + ", " + lit + ")", nil
+	default:
+		// "|="
+		return "(" + attr + "=" + lit + " or starts-with(" + attr + ", " + xpathLiteral(val+"-") + "))", nil
+	}
+}
+
+func (p *cssParser) pseudoClass() (string, error) {
+	name := p.ident()
+	switch name {
+	case "first-child":
+		return "not(preceding-sibling::*)", nil
+	case "last-child":
+		return "not(following-sibling::*)", nil
+	case "only-child":
+		return "not(preceding-sibling::*) and not(following-sibling::*)", nil
+	case "empty":
+		return "not(*) and not(text())", nil
+	}
+
+	if !p.consume("(") {
+		return "", errors.New("Unsupported pseudo-class :" + name)
+	}
+	arg := p.until(')')
+	if !p.consume(")") {
+		return "", errors.New("Missing ')'")
+	}
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case "nth-child":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return "", errors.New("Only numbers are supported by :nth-child()")
+		}
+		return "count(preceding-sibling::*) = " + strconv.Itoa(n-1), nil
+	case "not":
+		sub := &cssParser{s: arg}
+		step, err := sub.compound()
+		if err != nil {
+			return "", err
+		}
+		if !sub.done() {
+			return "", errors.New("Only simple selectors are supported by :not()")
+		}
+		return "not(" + step.condition() + ")", nil
+	case "contains":
+		sub := &cssParser{s: arg}
+		text, err := sub.value()
+		if err != nil {
+			return "", err
+		}
+		return "contains(., " + xpathLiteral(text) + ")", nil
+	}
+	return "", errors.New("Unsupported pseudo-class :" + name)
+}
+
+// quoted or bare value
+func (p *cssParser) value() (string, error) {
+	if p.done() {
+		return "", errors.New("Missing value")
+	}
+	quote := p.s[p.pos]
+	if quote == '"' || quote == '\'' {
+		p.pos++
+		val := p.until(quote)
+		if !p.consume(string(quote)) {
+			return "", errors.New("Unterminated string")
+		}
+		return val, nil
+	}
+	val := p.ident()
+	if val == "" {
+		return "", errors.New("Missing value")
+	}
+	return val, nil
+}
+
+func (p *cssParser) ident() string {
+	start := p.pos
+	for !p.done() {
+		c := p.s[p.pos]
+		if c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80 {
+			p.pos++
+		} else {
+			break
+		}
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *cssParser) until(c byte) string {
+	start := p.pos
+	for !p.done() && p.s[p.pos] != c {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *cssParser) consume(token string) bool {
+	if strings.HasPrefix(p.s[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+// returns true if any space skipped
+func (p *cssParser) skipSpaces() bool {
+	start := p.pos
+	for !p.done() && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *cssParser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+// splits by separator outside of brackets and quotes
+func splitTopLevel(s string, sep byte) ([]string, error) {
+	res := make([]string, 0)
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case c == sep && depth == 0:
+			res = append(res, s[start:i])
+			start = i + 1
+		}
+	}
+	if quote != 0 || depth != 0 {
+		return nil, errors.New("Unbalanced quotes or brackets\n CSS selector: " + s)
+	}
+	return append(res, s[start:]), nil
+}
+
+// whitespace separated word test, like class names
+func containsWord(attr, word string) string {
+	return "contains(concat(' ', normalize-space(" + attr + "), ' '), " + xpathLiteral(" "+word+" ") + ")"
+}
+
+func xpathLiteral(s string) string {
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'"
+	}
+	if !strings.Contains(s, "\"") {
+		return "\"" + s + "\""
+	}
+	parts := strings.Split(s, "'")
+	return "concat('" + strings.Join(parts, "', \"'\", '") + "')"
+}
@@ -0,0 +1,73 @@
+// patterns
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/antchfx/xquery/html"
+	"github.com/stretchr/testify/assert"
+)
+
+var cssHtml = `<html><body>
+	<div id="list">
+		<div class="item featured" data-id="1"><a href="/a">First</a><span class="price">10</span></div>
+		<div class="item" data-id="2"><a href="/b">Second</a><span class="price">20</span><em>new</em></div>
+		<div class="item-like" data-id="3"><a href="/c">Third</a></div>
+		<p class="note">Note's text</p>
+	</div>
+</body></html>`
+
+func TestCssToXpath(t *testing.T) {
+	n, _ := htmlquery.Parse(strings.NewReader(cssHtml))
+
+	var cases = []struct {
+		selector string
+		result   []interface{}
+	}{
+		{"div.item > a::text", []interface{}{"First", "Second"}},
+		{"#list .featured a::attr(href)", []interface{}{"/a"}},
+		{"div[data-id='2'] a", []interface{}{"Second"}},
+		{"div[class^=item] span.price", []interface{}{"10", "20"}},
+		{"div[class$=\"like\"] a", []interface{}{"Third"}},
+		{"div[class*=tem-] a", []interface{}{"Third"}},
+		{"div[class~=featured] a", []interface{}{"First"}},
+		{"div.item:not(.featured) > a", []interface{}{"Second"}},
+		{"div.item:first-child span", []interface{}{"10"}},
+		{"#list > div:nth-child(3) a", []interface{}{"Third"}},
+		{"a + span", []interface{}{"10", "20"}},
+		{"a ~ em", []interface{}{"new"}},
+		{"p:contains(\"Note's\")", []interface{}{"Note's text"}},
+		{"span.price, em", []interface{}{"10", "20", "new"}},
+	}
+
+	for _, c := range cases {
+		f := &Field{Title: "Test", Type: "[]string", Path: "css:" + c.selector}
+		cf, err := f.Compile()
+		if assert.NoError(t, err, c.selector) {
+			assert.Equal(t, c.result, cf.Retrieve(n), c.selector)
+		}
+	}
+
+	for _, bad := range []string{"", "div >", "> a", "div[", "a::before", "a::text b", "li:nth-child(odd)", "div.", "a,"} {
+		_, err := CssToXpath(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestCssXpathRules(t *testing.T) {
+	f := &Field{
+		Title: "Item",
+		Type:  "[]html",
+		Path:  "css:div.item",
+		XData: &XpathRules{
+			Exclude: "css:em",
+			Remove:  "css:span.price",
+		},
+	}
+	cf, err := f.Compile()
+	assert.NoError(t, err)
+
+	n, _ := htmlquery.Parse(strings.NewReader(cssHtml))
+	assert.Equal(t, []interface{}{`<div><a>First</a></div>`}, cf.Retrieve(n))
+}
@@ -0,0 +1,158 @@
+// patterns
+package parser
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// layouts tried if field has no <Layout> declared
+var defaultLayouts = []string{
+	time.RFC3339,
+	time.RFC1123,
+	time.RFC1123Z,
+	time.RFC850,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"01/02/2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"Jan 2 2006",
+	"2 Jan 2006",
+	"2 January 2006",
+	"Mon, Jan 2",
+	"Jan 2",
+	"January 2",
+	"2 Jan",
+}
+
+// relative dates like: "3 days ago", "an hour ago", "30+ days ago"
+var relativeDate = regexp.MustCompile(`(?i)\b(\d+|an?|one)\+?\s*(seconds?|secs?|minutes?|mins?|hours?|hrs?|h|days?|d|weeks?|wks?|w|months?|mos?|years?|yrs?|y)\s+ago\b`)
+
+// Set layouts and timezone used to parse "time" and "date" values.
+// Layouts are newline separated Go time layouts, like: "Jan 2, 2006"
+func (t *Type) SetLayout(layouts string, timezone string) error {
+	if !t.isTime {
+		if strings.TrimSpace(layouts) != "" || timezone != "" {
+			return errors.New("Layout and timezone are only allowed for time and date types")
+		}
+		return nil
+	}
+
+	t.layouts = nil
+	for _, l := range lineSplit.Split(layouts, -1) {
+		l = strings.TrimSpace(l)
+		if len(l) > 0 {
+			t.layouts = append(t.layouts, l)
+		}
+	}
+	if len(t.layouts) == 0 {
+		t.layouts = defaultLayouts
+	}
+
+	t.location = time.UTC
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return errors.New("Unrecognized timezone " + timezone)
+		}
+		t.location = loc
+	}
+	return nil
+}
+
+// Parses absolute or relative date anchored at "now".
+// Returns RFC 3339 formatted string: date-time for "time" type and full-date for "date" type.
+func (t *Type) parseTime(data []byte, now time.Time) (interface{}, error) {
+	s := strings.TrimSpace(string(data))
+	if s == "" {
+		return nil, errors.New("Empty date")
+	}
+
+	loc := t.location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+
+	res, ok := parseRelativeDate(s, now)
+	if !ok {
+		layouts := t.layouts
+		if len(layouts) == 0 {
+			layouts = defaultLayouts
+		}
+
+		// drop leading words one by one, so "Posted Oct 2" matches "Jan 2"
+		words := strings.Fields(s)
+		for i := 0; i < len(words) && !ok; i++ {
+			candidate := strings.Join(words[i:], " ")
+			for _, layout := range layouts {
+				parsed, err := time.ParseInLocation(layout, candidate, loc)
+				if err == nil {
+					res, ok = parsed, true
+					break
+				}
+			}
+		}
+		if !ok {
+			return nil, errors.New("Can't parse date: " + s)
+		}
+
+		// layouts without year, e.g. "Jan 2": assume the latest such date not in future
+		if res.Year() == 0 {
+			res = res.AddDate(now.Year(), 0, 0)
+			if res.After(now.AddDate(0, 0, 1)) {
+				res = res.AddDate(-1, 0, 0)
+			}
+		}
+	}
+
+	if t.isDate {
+		return res.Format("2006-01-02"), nil
+	}
+	return res.Format(time.RFC3339), nil
+}
+
+func parseRelativeDate(s string, now time.Time) (time.Time, bool) {
+	lower := strings.ToLower(s)
+	switch {
+	case strings.Contains(lower, "just now"), lower == "now", strings.Contains(lower, "today"):
+		return now, true
+	case strings.Contains(lower, "yesterday"):
+		return now.AddDate(0, 0, -1), true
+	}
+
+	m := relativeDate.FindStringSubmatch(lower)
+	if m == nil {
+		return now, false
+	}
+
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		// "a", "an", "one"
+		n = 1
+	}
+
+	unit := m[2]
+	switch {
+	case strings.HasPrefix(unit, "s"):
+		return now.Add(-time.Duration(n) * time.Second), true
+	case strings.HasPrefix(unit, "mi"):
+		return now.Add(-time.Duration(n) * time.Minute), true
+	case strings.HasPrefix(unit, "h"):
+		return now.Add(-time.Duration(n) * time.Hour), true
+	case strings.HasPrefix(unit, "d"):
+		return now.AddDate(0, 0, -n), true
+	case strings.HasPrefix(unit, "w"):
+		return now.AddDate(0, 0, -7*n), true
+	case strings.HasPrefix(unit, "mo"):
+		return now.AddDate(0, -n, 0), true
+	case strings.HasPrefix(unit, "y"):
+		return now.AddDate(-n, 0, 0), true
+	}
+	return now, false
+}
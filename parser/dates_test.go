@@ -0,0 +1,67 @@
+// patterns
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/antchfx/xquery/html"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2018, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	var cases = []struct {
+		typeName string
+		layout   string
+		in       string
+		out      string
+	}{
+		{"time", "", "2018-01-02T15:04:05Z", "2018-01-02T15:04:05Z"},
+		{"time", "", "3 days ago", "2018-03-07T12:00:00Z"},
+		{"time", "", "Posted an hour ago", "2018-03-10T11:00:00Z"},
+		{"date", "", "yesterday", "2018-03-09"},
+		{"date", "", "30+ days ago", "2018-02-08"},
+		{"date", "", "Posted Oct 2", "2017-10-02"},
+		{"date", "", "Posted Mar 2", "2018-03-02"},
+		{"date", "02.01.2006", "10.03.2018", "2018-03-10"},
+	}
+
+	for _, c := range cases {
+		tp, _ := CompileType(c.typeName)
+		assert.NoError(t, tp.SetLayout(c.layout, ""))
+		val, err := tp.parseTime([]byte(c.in), now)
+		if assert.NoError(t, err, c.in) {
+			assert.Equal(t, c.out, val, c.in)
+		}
+	}
+
+	tp, _ := CompileType("time")
+	_, err := tp.parseTime([]byte("not a date"), now)
+	assert.Error(t, err)
+	assert.Error(t, tp.SetLayout("", "Nowhere/Nothing"))
+
+	tp, _ = CompileType("string")
+	assert.Error(t, tp.SetLayout("2006", ""))
+}
+
+func TestRetrieve_time(t *testing.T) {
+	f := &Field{
+		Title:    "Posted",
+		Type:     "time",
+		Path:     "//span",
+		Layout:   "2006-01-02 15:04",
+		Timezone: "America/New_York",
+	}
+	cf, err := f.Compile()
+	assert.NoError(t, err)
+
+	n, _ := htmlquery.Parse(strings.NewReader(`<html><body><span>2018-03-10 09:30</span></body></html>`))
+	assert.Equal(t, "2018-03-10T09:30:00-05:00", cf.Retrieve(n))
+
+	n, _ = htmlquery.Parse(strings.NewReader(`<html><body><span>2 hours ago</span></body></html>`))
+	ctx := &Context{Date: time.Date(2018, time.March, 10, 17, 0, 0, 0, time.UTC)}
+	assert.Equal(t, "2018-03-10T10:00:00-05:00", cf.RetrieveContext(ctx, n))
+}
@@ -0,0 +1,301 @@
+// patterns
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Results are decoded into Go structs by "descry" tags, like:
+//
+//	type Job struct {
+//		Title  string    `descry:"Title"`
+//		Salary *int      `descry:"Salary"`
+//		Tags   []string  `descry:"Tags"`
+//		Posted time.Time `descry:"Posted"` // "time" and "date" values are parsed
+//	}
+//
+//	var res struct {
+//		Jobs []Job `descry:"startupgigs/stackoverflow.com/Body.xml/Body/Job"`
+//	}
+//	err := patterns.ApplyInto(url, content, &res)
+//
+// tag is a key or "/" separated path of keys within nested results (directories, pattern files, field titles);
+// field name is used if there is no tag, "-" skips field. Missing values leave fields as they are;
+// pointers are allocated for values only, so nil pointer means value is missing
+const decodeTag = "descry"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Applies patterns to input and decodes results into struct pointed by dst
+func (p *Patterns) ApplyInto(url string, content io.Reader, dst interface{}) error {
+	data, err := p.Apply(url, content)
+	if err != nil {
+		return err
+	}
+	return Decode(data, dst)
+}
+
+// Decodes result of pattern (or any part of it) into value pointed by dst
+func Decode(data interface{}, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("Decode destination must be non-nil pointer")
+	}
+	return decodeValue(data, v.Elem(), "")
+}
+
+func decodeValue(data interface{}, v reflect.Value, path string) error {
+	if data == nil {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := decodeValue(data, elem.Elem(), path); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Interface:
+		if reflect.TypeOf(data).AssignableTo(v.Type()) {
+			v.Set(reflect.ValueOf(data))
+			return nil
+		}
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return decodeTime(data, v, path)
+		}
+		if m, ok := data.(map[string]interface{}); ok {
+			return decodeStruct(m, v, path)
+		}
+	case reflect.Map:
+		m, ok := data.(map[string]interface{})
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		res := reflect.MakeMap(v.Type())
+		for key, item := range m {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeValue(item, elem, joinPath(path, key)); err != nil {
+				return err
+			}
+			res.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		v.Set(res)
+		return nil
+	case reflect.Slice:
+		list, ok := data.([]interface{})
+		if !ok {
+			break
+		}
+		res := reflect.MakeSlice(v.Type(), len(list), len(list))
+		for i, item := range list {
+			if err := decodeValue(item, res.Index(i), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		v.Set(res)
+		return nil
+	case reflect.String:
+		switch s := data.(type) {
+		case string:
+			v.SetString(s)
+			return nil
+		case json.Number:
+			// numbers of structured data maps
+			v.SetString(s.String())
+			return nil
+		}
+	case reflect.Bool:
+		if b, ok := data.(bool); ok {
+			v.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return decodeNumber(data, v, path)
+	}
+	return decodeError(data, v, path)
+}
+
+// sets struct fields by their tags
+func decodeStruct(m map[string]interface{}, v reflect.Value, path string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+		tag := field.Tag.Get(decodeTag)
+		if tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = field.Name
+		}
+
+		data, ok := lookupPath(m, tag)
+		if !ok {
+			continue
+		}
+		if err := decodeValue(data, v.Field(i), joinPath(path, tag)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// value by "/" separated path of keys within nested maps
+func lookupPath(m map[string]interface{}, path string) (interface{}, bool) {
+	var data interface{} = m
+	for _, key := range strings.Split(path, "/") {
+		next, ok := data.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if data, ok = next[key]; !ok {
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// integers are decoded exactly, only fractional values and float targets go through float64
+func decodeNumber(data interface{}, v reflect.Value, path string) error {
+	// int64, uint64 (values above MaxInt64) or float64
+	var num interface{}
+	switch val := data.(type) {
+	case int:
+		num = int64(val)
+	case int64:
+		num = val
+	case uint:
+		num = uint64(val)
+	case float64:
+		num = val
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			num = i
+		} else if u, err := strconv.ParseUint(val.String(), 10, 64); err == nil {
+			num = u
+		} else if f, err := val.Float64(); err == nil {
+			num = f
+		} else {
+			return decodeError(data, v, path)
+		}
+	default:
+		return decodeError(data, v, path)
+	}
+
+	overflow := func() error {
+		return errors.New(describePath(path) + "value " + exprText(data) + " overflows " + v.Type().String())
+	}
+	isFloat := v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64
+	if f, ok := num.(float64); ok && !isFloat && f != math.Trunc(f) {
+		return errors.New(describePath(path) + "can't decode fractional number " + exprText(data) + " into " + v.Type().String())
+	}
+
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch n := num.(type) {
+		case int64:
+			f = float64(n)
+		case uint64:
+			f = float64(n)
+		case float64:
+			f = n
+		}
+		if v.OverflowFloat(f) {
+			return overflow()
+		}
+		v.SetFloat(f)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		switch n := num.(type) {
+		case int64:
+			if n < 0 {
+				return overflow()
+			}
+			u = uint64(n)
+		case uint64:
+			u = n
+		case float64:
+			if n < 0 || n >= 1<<64 {
+				return overflow()
+			}
+			u = uint64(n)
+		}
+		if v.OverflowUint(u) {
+			return overflow()
+		}
+		v.SetUint(u)
+	default:
+		var i int64
+		switch n := num.(type) {
+		case int64:
+			i = n
+		case uint64:
+			if n > math.MaxInt64 {
+				return overflow()
+			}
+			i = int64(n)
+		case float64:
+			if n < -(1<<63) || n >= 1<<63 {
+				return overflow()
+			}
+			i = int64(n)
+		}
+		if v.OverflowInt(i) {
+			return overflow()
+		}
+		v.SetInt(i)
+	}
+	return nil
+}
+
+// "time" and "date" values are RFC 3339 strings
+func decodeTime(data interface{}, v reflect.Value, path string) error {
+	switch val := data.(type) {
+	case time.Time:
+		v.Set(reflect.ValueOf(val))
+		return nil
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+			if t, err := time.Parse(layout, val); err == nil {
+				v.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return errors.New(describePath(path) + "can't decode " + strconv.Quote(val) + " into time.Time")
+	}
+	return decodeError(data, v, path)
+}
+
+func decodeError(data interface{}, v reflect.Value, path string) error {
+	return errors.New(describePath(path) + "can't decode " + reflect.TypeOf(data).String() + " into " + v.Type().String())
+}
+
+func describePath(path string) string {
+	if path == "" {
+		return ""
+	}
+	return path + ": "
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
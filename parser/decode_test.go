@@ -0,0 +1,162 @@
+// patterns
+package parser
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/antchfx/xquery/html"
+	"github.com/stretchr/testify/assert"
+)
+
+type decodeJob struct {
+	Title    string            `descry:"Title"`
+	Salary   *int              `descry:"Salary"`
+	Rating   float32           `descry:"Rating"`
+	Remote   bool              `descry:"Remote"`
+	Tags     []string          `descry:"Tags"`
+	Posted   time.Time         `descry:"Posted"`
+	Company  *decodeCompany    `descry:"Company"`
+	Extra    map[string]string `descry:"Extra"`
+	Raw      interface{}       `descry:"Tags"`
+	Skipped  string            `descry:"-"`
+	Location string
+}
+
+type decodeCompany struct {
+	Name string `descry:"Name"`
+}
+
+func TestDecode(t *testing.T) {
+	posted := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	data := map[string]interface{}{
+		"site": map[string]interface{}{
+			"Body.xml": map[string]interface{}{
+				"Body": map[string]interface{}{
+					"Job": []interface{}{
+						map[string]interface{}{
+							"Title":    "Go developer",
+							"Salary":   100000,
+							"Rating":   4.5,
+							"Remote":   true,
+							"Tags":     []interface{}{"go", "sql"},
+							"Posted":   "2019-05-01T00:00:00Z",
+							"Company":  map[string]interface{}{"Name": "Acme"},
+							"Extra":    map[string]interface{}{"Visa": "yes"},
+							"Skipped":  "x",
+							"Location": "Austin",
+						},
+						map[string]interface{}{
+							"Title":   "Designer",
+							"Salary":  nil,
+							"Company": nil,
+							"Posted":  "2019-05-01",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var res struct {
+		Jobs []decodeJob `descry:"site/Body.xml/Body/Job"`
+		None []decodeJob `descry:"site/Item.xml/Body/Job"`
+	}
+	assert.NoError(t, Decode(data, &res))
+
+	salary := 100000
+	assert.Equal(t, []decodeJob{
+		{
+			Title:    "Go developer",
+			Salary:   &salary,
+			Rating:   4.5,
+			Remote:   true,
+			Tags:     []string{"go", "sql"},
+			Posted:   posted,
+			Company:  &decodeCompany{Name: "Acme"},
+			Extra:    map[string]string{"Visa": "yes"},
+			Raw:      []interface{}{"go", "sql"},
+			Location: "Austin",
+		},
+		{Title: "Designer", Posted: posted},
+	}, res.Jobs)
+	assert.Nil(t, res.None)
+
+	var job decodeJob
+	assert.EqualError(t, Decode(map[string]interface{}{"Tags": "go"}, &job), "Tags: can't decode string into []string")
+	assert.EqualError(t, Decode(map[string]interface{}{"Posted": "yesterday"}, &job), `Posted: can't decode "yesterday" into time.Time`)
+	assert.EqualError(t, Decode(map[string]interface{}{"Salary": 1.5}, &job), "Salary: can't decode fractional number 1.5 into int")
+	assert.EqualError(t, Decode(map[string]interface{}{"Company": map[string]interface{}{"Name": 1}}, &job), "Company.Name: can't decode int into string")
+	var small struct {
+		N []int8 `descry:"N"`
+	}
+	assert.EqualError(t, Decode(map[string]interface{}{"N": []interface{}{1, 300}}, &small), "N[1]: value 300 overflows int8")
+	assert.Error(t, Decode(map[string]interface{}{}, job))
+}
+
+func TestApplyInto(t *testing.T) {
+	p := NewPatterns(nil)
+	assert.NoError(t, p.LoadXml(p.Tree, []byte(capturePatternStr), "capture.xml"))
+
+	var res struct {
+		Job struct {
+			JobId    int64  `descry:"JobId"`
+			Category string `descry:"Category"`
+			Title    string `descry:"Title"`
+		} `descry:"capture.xml/Job"`
+	}
+	assert.NoError(t, p.ApplyInto("https://example.com/design/jobs/123", strings.NewReader(`<html><body><h1>Designer</h1></body></html>`), &res))
+	assert.Equal(t, int64(123), res.Job.JobId)
+	assert.Equal(t, "DESIGN", res.Job.Category)
+	assert.Equal(t, "Designer", res.Job.Title)
+}
+
+func TestDecode_structured(t *testing.T) {
+	n, _ := htmlquery.Parse(strings.NewReader(structuredHtml))
+	cf, err := (&Field{Title: "Jobs", Type: "[]jsonld", Schema: "JobPosting"}).Compile()
+	assert.NoError(t, err)
+
+	// JSON-LD numbers are json.Number
+	var jobs []struct {
+		Title      string  `descry:"title"`
+		BaseSalary int     `descry:"baseSalary"`
+		Salary     float64 `descry:"baseSalary"`
+		Text       string  `descry:"baseSalary"`
+	}
+	assert.NoError(t, Decode(cf.Retrieve(n), &jobs))
+	if assert.Len(t, jobs, 2) {
+		assert.Equal(t, "Go developer", jobs[0].Title)
+		assert.Equal(t, 100000, jobs[0].BaseSalary)
+		assert.Equal(t, 100000.0, jobs[0].Salary)
+		assert.Equal(t, "100000", jobs[0].Text)
+	}
+}
+
+func TestDecode_bigNumbers(t *testing.T) {
+	var res struct {
+		I int64   `descry:"I"`
+		U uint64  `descry:"U"`
+		F float64 `descry:"F"`
+	}
+	assert.NoError(t, Decode(map[string]interface{}{
+		"I": json.Number("9223372036854775807"),
+		"U": json.Number("18446744073709551615"),
+		"F": json.Number("1.5e3"),
+	}, &res))
+	assert.Equal(t, int64(math.MaxInt64), res.I)
+	assert.Equal(t, uint64(math.MaxUint64), res.U)
+	assert.Equal(t, 1500.0, res.F)
+
+	assert.NoError(t, Decode(map[string]interface{}{"I": int64(math.MaxInt64 - 1), "U": json.Number("9223372036854775809")}, &res))
+	assert.Equal(t, int64(math.MaxInt64-1), res.I)
+	assert.Equal(t, uint64(1<<63+1), res.U)
+
+	assert.EqualError(t, Decode(map[string]interface{}{"I": json.Number("9223372036854775808")}, &res), "I: value 9223372036854775808 overflows int64")
+	// float64(MaxInt64) is 2^63
+	assert.EqualError(t, Decode(map[string]interface{}{"I": float64(math.MaxInt64)}, &res), "I: value 9223372036854776000 overflows int64")
+	assert.EqualError(t, Decode(map[string]interface{}{"U": json.Number("-1")}, &res), "U: value -1 overflows uint64")
+	assert.EqualError(t, Decode(map[string]interface{}{"U": json.Number("18446744073709551616")}, &res), "U: value 18446744073709551616 overflows uint64")
+}
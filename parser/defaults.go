@@ -0,0 +1,119 @@
+// patterns
+package parser
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Missing sub-fields of struct are handled in order:
+//
+//	default="n/a"               value used instead, unless default is empty as well
+//	optional="true"             struct keeps null value
+//	required="drop"             (default) whole struct is dropped
+//	required="warn"             struct keeps null value, diagnostic is reported
+//	required="fail-document"    nothing is retrieved by the pattern
+//	omitempty="true"            null value (or empty list) is left out of struct rather than kept as null
+const (
+	RequiredDrop = "drop"
+	RequiredWarn = "warn"
+	RequiredFail = "fail-document"
+)
+
+func (f *Field) compileDefaults(c *CompiledField) error {
+	switch f.Required {
+	case "", RequiredDrop, RequiredWarn, RequiredFail:
+	default:
+		return errors.New("Failed to compile " + f.Title + ". Unrecognized required level " + f.Required)
+	}
+	if f.Required != "" && f.Optional {
+		return errors.New("Failed to compile " + f.Title + ". Field can't be both optional and required")
+	}
+
+	if f.Default != "" {
+		if kind := c.dataType.base().kind; kind == reflect.Struct || kind == reflect.Map {
+			return errors.New("Failed to compile " + f.Title + ". Default isn't supported for " + c.dataType.String() + " type")
+		}
+		// relative URLs are only resolved against document
+		if !c.dataType.base().isUrl {
+			if _, err := c.dataType.base().Convert([]byte(f.Default)); err != nil {
+				return errors.New("Failed to compile " + f.Title + ". Default " + err.Error())
+			}
+		}
+	}
+
+	c.def = f.Default
+	c.required = f.Required
+	if c.required == "" {
+		c.required = RequiredDrop
+	}
+	c.omitEmpty = f.OmitEmpty
+	return nil
+}
+
+// nil or empty list
+func isEmptyValue(val interface{}) bool {
+	if list, ok := val.([]interface{}); ok {
+		return len(list) == 0
+	}
+	return val == nil
+}
+
+// default value of missing field converted to field type; nil if there is no default
+func (f *CompiledField) defaultValue(ctx *Context, report *Report) interface{} {
+	if f.def == "" {
+		return nil
+	}
+	val := f.convertValue(ctx, []byte(f.def), report)
+	if val == nil {
+		return nil
+	}
+	report.add(DiagDefault, "value is missing, default used", []byte(f.def))
+	if f.multiple {
+		return []interface{}{val}
+	}
+	return val
+}
+
+// handles missing value of sub-field according to its required level;
+// returns false if struct has to be dropped
+func (f *CompiledField) missing(ctx *Context, child *CompiledField, report *Report) bool {
+	if child.optional {
+		return true
+	}
+	switch child.required {
+	case RequiredWarn:
+		report.add(DiagRequired, "required field "+child.title+" is missing, null kept", nil)
+		return true
+	case RequiredFail:
+		report.add(DiagRequired, "required field "+child.title+" is missing, document failed", nil)
+		ctx.fail("required field " + child.title + " of " + f.title + " is missing")
+		return false
+	}
+	report.add(DiagRequired, "required field "+child.title+" is missing, struct dropped", nil)
+	return false
+}
+
+// returns copy of context collecting the reason document failed, if any
+func (ctx *Context) withFailure() *Context {
+	c := &Context{}
+	if ctx != nil {
+		*c = *ctx
+	}
+	c.failure = new(string)
+	return c
+}
+
+// fails document being retrieved; only the first reason is kept
+func (ctx *Context) fail(reason string) {
+	if ctx != nil && ctx.failure != nil && *ctx.failure == "" {
+		*ctx.failure = reason
+	}
+}
+
+func (ctx *Context) failed() string {
+	if ctx == nil || ctx.failure == nil {
+		return ""
+	}
+	return *ctx.failure
+}
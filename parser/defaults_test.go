@@ -0,0 +1,94 @@
+// patterns
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var defaultsPatternStr = `
+<Pattern mime="html">
+	<Field title="Job" type="[]struct">
+		<Path>//li</Path>
+		<Field title="Title" type="string">
+			<Path>.//h2</Path>
+		</Field>
+		<Field title="Salary" type="int" default="0">
+			<Path>.//span[@class='salary']</Path>
+		</Field>
+		<Field title="Tags" type="[]string" default="other">
+			<Path>.//span[@class='tag']</Path>
+		</Field>
+		<Field title="Company" type="string" required="warn">
+			<Path>.//span[@class='company']</Path>
+		</Field>
+		<Field title="Remote" type="bool" optional="true" omitempty="true">
+			<Path>.//span[@class='remote']</Path>
+		</Field>
+		<Field title="Location" type="string" optional="true">
+			<Path>.//span[@class='location']</Path>
+		</Field>
+	</Field>
+</Pattern>
+`
+
+func TestRetrieve_defaults(t *testing.T) {
+	p := NewPatterns(nil)
+	assert.NoError(t, p.LoadXml(p.Tree, []byte(defaultsPatternStr), "defaults.xml"))
+
+	data, reports, err := p.ApplyWithReport("", strings.NewReader(`<html><body><ul>
+		<li><h2>Go developer</h2><span class="salary">100</span><span class="tag">go</span>
+			<span class="company">Acme</span><span class="remote">true</span></li>
+		<li><h2>Designer</h2></li>
+		<li><span class="company">No title</span></li>
+	</ul></body></html>`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"defaults.xml": map[string]interface{}{
+			"Job": []interface{}{
+				map[string]interface{}{
+					"Title":    "Go developer",
+					"Salary":   100,
+					"Tags":     []interface{}{"go"},
+					"Company":  "Acme",
+					"Remote":   true,
+					"Location": nil,
+				},
+				map[string]interface{}{
+					"Title":    "Designer",
+					"Salary":   0,
+					"Tags":     []interface{}{"other"},
+					"Company":  nil,
+					"Location": nil,
+				},
+			},
+		},
+	}, data)
+
+	report := reports["defaults.xml"]
+	assert.Equal(t, DiagRequired, report.Diagnostics[0].Kind)
+	assert.Equal(t, "required field Company is missing, null kept", report.Diagnostics[0].Message)
+	assert.Equal(t, "required field Title is missing, struct dropped", report.Diagnostics[1].Message)
+	assert.Equal(t, DiagDefault, report.child("Salary").Diagnostics[0].Kind)
+
+	// missing required="fail-document" field discards everything retrieved by pattern
+	failing := strings.Replace(defaultsPatternStr, `type="string" required="warn"`, `type="string" required="fail-document"`, 1)
+	assert.NoError(t, p.LoadXml(p.Tree, []byte(failing), "defaults.xml"))
+	data, reports, err = p.ApplyWithReport("", strings.NewReader(`<html><body><ul><li><h2>Designer</h2></li></ul></body></html>`))
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+	report = reports["defaults.xml"]
+	assert.Equal(t, "document failed: required field Company of Job is missing", report.Diagnostics[len(report.Diagnostics)-1].Message)
+
+	for _, bad := range []*Field{
+		&Field{Title: "A", Type: "string", Path: "//a", Required: "maybe"},
+		&Field{Title: "A", Type: "string", Path: "//a", Required: "warn", Optional: true},
+		&Field{Title: "A", Type: "int", Path: "//a", Default: "n/a"},
+		&Field{Title: "A", Type: "struct", Path: "//a", Default: "n/a"},
+	} {
+		_, err := bad.Compile()
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,74 @@
+// patterns
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xquery/html"
+	"golang.org/x/net/html"
+)
+
+// Parsed document patterns are applied to. Only patterns of the same mime are applied
+type Document struct {
+	// "html", "json" or "xml"
+	Mime string
+
+	Html *html.Node
+	Json interface{}
+	Xml  *xmlquery.Node
+}
+
+// Parses content according to mime: "html", "json" or "xml"
+func ParseDocument(mime string, content io.Reader) (*Document, error) {
+	doc := &Document{Mime: mime}
+	switch mime {
+	case "html", "":
+		doc.Mime = "html"
+		node, err := htmlquery.Parse(content)
+		if err != nil {
+			return nil, err
+		}
+		doc.Html = node
+	case "json":
+		decoder := json.NewDecoder(content)
+		// keep numbers as they are in document
+		decoder.UseNumber()
+		err := decoder.Decode(&doc.Json)
+		if err != nil {
+			return nil, err
+		}
+	case "xml":
+		node, err := xmlquery.Parse(content)
+		if err != nil {
+			return nil, err
+		}
+		doc.Xml = node
+	default:
+		return nil, errors.New("Unsupported mime " + mime)
+	}
+	return doc, nil
+}
+
+// Returns pattern mime ("html", "json", "xml") for HTTP Content-Type header; empty string if not supported
+func MimeType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+
+	// other "+json" and "+xml" types (SVG, problem details etc.) aren't documents patterns are written for
+	switch mediaType {
+	case "text/html", "application/xhtml+xml":
+		return "html"
+	case "application/json", "text/json":
+		return "json"
+	case "application/xml", "text/xml", "application/rss+xml", "application/atom+xml":
+		return "xml"
+	}
+	return ""
+}
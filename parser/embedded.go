@@ -0,0 +1,232 @@
+// patterns
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/antchfx/xquery/html"
+	"golang.org/x/net/html"
+)
+
+// Retrieves struct from JSON embedded into nodes found by Path, like:
+//
+//	<script id="__NEXT_DATA__" type="application/json">{...}</script>
+//	<script>window.__INITIAL_STATE__ = {...};</script>
+//
+// sub-fields address parsed object with JSONPath
+func (f *CompiledField) retrieveEmbedded(ctx *Context, root *html.Node, report *Report) interface{} {
+	for _, query := range f.paths(ctx, report) {
+		res := make([]interface{}, 0)
+		hits := 0
+		for _, node := range htmlquery.Find(root, query.String()) {
+			hits++
+			data, ok := f.embeddedJson([]byte(htmlquery.InnerText(node)), report)
+			if !ok {
+				continue
+			}
+			if val := f.retrieveJsonStruct(ctx, data, data, report); val != nil {
+				res = append(res, val)
+				if !f.multiple {
+					break
+				}
+			}
+		}
+		report.hit(query.String(), hits)
+
+		if len(res) > 0 {
+			report.matched(query.String(), len(res))
+			if f.multiple {
+				return res
+			}
+			return res[0]
+		}
+	}
+
+	if f.multiple {
+		return []interface{}{}
+	}
+	return nil
+}
+
+// extracts object literal from node text and parses it
+func (f *CompiledField) embeddedJson(text []byte, report *Report) (interface{}, bool) {
+	if !f.testData(text, report) {
+		return nil, false
+	}
+	text = f.data.FindOne(f.data.Clean(text))
+
+	literal, err := findLiteral(string(text), f.assignment)
+	if err != nil {
+		report.add(DiagConversion, err.Error(), text)
+		return nil, false
+	}
+
+	data, err := parseJsLiteral(literal)
+	if err != nil {
+		report.add(DiagConversion, "bad embedded JSON: "+err.Error(), []byte(literal))
+		return nil, false
+	}
+	return data, true
+}
+
+// matches assignment to JavaScript variable, like: window.__INITIAL_STATE__ =
+func assignmentRegexp(variable string) *regexp.Regexp {
+	if variable == "" {
+		return nil
+	}
+	return regexp.MustCompile(`(?:^|[^\w$.])` + regexp.QuoteMeta(variable) + `\s*=\s*`)
+}
+
+// finds object or array literal following assignment (first one if there is no assignment)
+func findLiteral(text string, assignment *regexp.Regexp) (string, error) {
+	start := 0
+	if assignment != nil {
+		loc := assignment.FindStringIndex(text)
+		if loc == nil {
+			return "", errors.New("Variable assignment not found")
+		}
+		start = loc[1]
+	}
+
+	i := strings.IndexAny(text[start:], "{[")
+	if i < 0 {
+		return "", errors.New("Object literal not found")
+	}
+	start += i
+
+	end := literalEnd(text, start)
+	if end < 0 {
+		return "", errors.New("Unterminated object literal")
+	}
+	return text[start:end], nil
+}
+
+// returns position after closing bracket of literal starting at "start"; -1 if it's not closed
+func literalEnd(text string, start int) int {
+	depth := 0
+	var quote byte
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// parses JSON; JavaScript object literals (bare and single quoted keys, undefined, trailing commas)
+// are converted to JSON first
+func parseJsLiteral(literal string) (interface{}, error) {
+	var data interface{}
+	decoder := json.NewDecoder(strings.NewReader(literal))
+	// keep numbers as they are in document
+	decoder.UseNumber()
+	err := decoder.Decode(&data)
+	if err == nil {
+		return data, nil
+	}
+
+	converted, convErr := jsToJson(literal)
+	if convErr != nil {
+		return nil, err
+	}
+	decoder = json.NewDecoder(bytes.NewReader(converted))
+	decoder.UseNumber()
+	if convErr = decoder.Decode(&data); convErr != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func jsToJson(literal string) ([]byte, error) {
+	var buf bytes.Buffer
+	for i := 0; i < len(literal); i++ {
+		c := literal[i]
+		switch {
+		case c == '"' || c == '\'':
+			// strings are emitted double quoted
+			end := i + 1
+			var s strings.Builder
+			for ; end < len(literal) && literal[end] != c; end++ {
+				switch {
+				case literal[end] == '\\' && end+1 < len(literal):
+					end++
+					if literal[end] == '\'' {
+						s.WriteByte('\'')
+					} else {
+						s.WriteByte('\\')
+						s.WriteByte(literal[end])
+					}
+				case literal[end] == '"':
+					s.WriteString(`\"`)
+				default:
+					s.WriteByte(literal[end])
+				}
+			}
+			if end >= len(literal) {
+				return nil, errors.New("Unterminated string")
+			}
+			buf.WriteString(`"` + s.String() + `"`)
+			i = end
+		case c >= '0' && c <= '9':
+			// numbers are emitted as they are, including exponent
+			end := i
+			for end < len(literal) && (isWordChar(literal[end]) || literal[end] == '.' ||
+				(literal[end] == '+' || literal[end] == '-') && (literal[end-1] == 'e' || literal[end-1] == 'E')) {
+				end++
+			}
+			buf.WriteString(literal[i:end])
+			i = end - 1
+		case c == ',':
+			// drop trailing commas
+			next := strings.TrimLeft(literal[i+1:], " \t\r\n")
+			if !strings.HasPrefix(next, "}") && !strings.HasPrefix(next, "]") {
+				buf.WriteByte(c)
+			}
+		case isWordChar(c):
+			end := i
+			for end < len(literal) && isWordChar(literal[end]) {
+				end++
+			}
+			word := literal[i:end]
+			switch {
+			case strings.HasPrefix(strings.TrimLeft(literal[end:], " \t\r\n"), ":"):
+				// bare key
+				buf.WriteString(`"` + word + `"`)
+			case word == "undefined":
+				buf.WriteString("null")
+			case word == "true" || word == "false" || word == "null":
+				buf.WriteString(word)
+			default:
+				return nil, errors.New("Unsupported expression " + word)
+			}
+			i = end - 1
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// JavaScript identifier char
+func isWordChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
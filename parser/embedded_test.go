@@ -0,0 +1,139 @@
+// patterns
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/antchfx/xquery/html"
+	"github.com/stretchr/testify/assert"
+)
+
+var embeddedHtml = `<html><head>
+	<script>var config = {debug: false};</script>
+	<script>
+		window.__INITIAL_STATE__ = {
+			jobs: [
+				{id: 1, title: 'Go developer', salary: 1.5e5, remote: true, tags: ["go", "sql",]},
+				{id: 2, title: "Designer's job", salary: 90000, remote: undefined, tags: []},
+			],
+		};
+	</script>
+	<script id="__NEXT_DATA__" type="application/json">{"props": {"page": {"title": "Jobs", "total": "2"}}}</script>
+</head><body></body></html>`
+
+var embeddedPatternStr = `
+<Pattern mime="html">
+	<Field title="Site" type="struct">
+		<Path>/html</Path>
+		<Field title="Page" type="struct" source="json">
+			<Path>//script[@id='__NEXT_DATA__']</Path>
+			<Field title="Title" type="string">
+				<Path>$.props.page.title</Path>
+			</Field>
+			<Field title="Total" type="int">
+				<Path>props.page.total</Path>
+			</Field>
+		</Field>
+		<Field title="State" type="struct" source="json" var="window.__INITIAL_STATE__">
+			<Path>//script[not(@src)]</Path>
+			<Field title="Job" type="[]struct">
+				<Path>$.jobs[*]</Path>
+				<Field title="ID" type="int">
+					<Path>id</Path>
+				</Field>
+				<Field title="Title" type="string">
+					<Path>title</Path>
+				</Field>
+				<Field title="Salary" type="float64">
+					<Path>salary</Path>
+				</Field>
+				<Field title="Remote" type="bool" optional="true">
+					<Path>remote</Path>
+				</Field>
+				<Field title="Tags" type="[]string">
+					<Path>tags</Path>
+				</Field>
+			</Field>
+		</Field>
+	</Field>
+</Pattern>
+`
+
+func TestRetrieveEmbedded(t *testing.T) {
+	pn := NewPatterns(nil)
+	assert.NoError(t, pn.LoadXml(pn.Tree, []byte(embeddedPatternStr), "embedded.xml"))
+
+	n, _ := htmlquery.Parse(strings.NewReader(embeddedHtml))
+	assert.Equal(t, map[string]interface{}{
+		"embedded.xml": map[string]interface{}{
+			"Site": map[string]interface{}{
+				"Page": map[string]interface{}{
+					"Title": "Jobs",
+					"Total": 2,
+				},
+				"State": map[string]interface{}{
+					"Job": []interface{}{
+						map[string]interface{}{
+							"ID":     1,
+							"Title":  "Go developer",
+							"Salary": 150000.0,
+							"Remote": true,
+							"Tags":   []interface{}{"go", "sql"},
+						},
+						map[string]interface{}{
+							"ID":     2,
+							"Title":  "Designer's job",
+							"Salary": 90000.0,
+							"Remote": nil,
+							"Tags":   []interface{}{},
+						},
+					},
+				},
+			},
+		},
+	}, pn.Tree.ApplyPatterns("", n))
+}
+
+func TestRetrieveEmbedded_errors(t *testing.T) {
+	n, _ := htmlquery.Parse(strings.NewReader(`<script>window.data = {a: new Date()};</script>`))
+
+	f := &Field{Title: "Data", Type: "struct", Source: "json", Var: "window.data", Path: "//script",
+		Field: []*Field{&Field{Title: "A", Type: "string", Path: "a"}}}
+	cf, err := f.Compile()
+	assert.NoError(t, err)
+	val, report := cf.RetrieveWithReport(nil, n)
+	assert.Nil(t, val)
+	assert.Equal(t, DiagConversion, report.Diagnostics[0].Kind)
+
+	f.Var = "window.missing"
+	cf, err = f.Compile()
+	assert.NoError(t, err)
+	val, report = cf.RetrieveWithReport(nil, n)
+	assert.Nil(t, val)
+	assert.Equal(t, "Variable assignment not found", report.Diagnostics[0].Message)
+
+	for _, bad := range []*Field{
+		&Field{Title: "Data", Type: "string", Source: "json", Path: "//script"},
+		&Field{Title: "Data", Type: "struct", Source: "json"},
+		&Field{Title: "Data", Type: "struct", Source: "yaml", Path: "//script"},
+		&Field{Title: "Data", Type: "struct", Var: "window.data", Path: "//script"},
+	} {
+		_, err := bad.Compile()
+		assert.Error(t, err)
+	}
+}
+
+func TestRetrieveEmbedded_noPath(t *testing.T) {
+	n, _ := htmlquery.Parse(strings.NewReader(embeddedHtml))
+
+	f := &Field{Title: "Page", Type: "struct", Source: "json", Path: "//script[@id='__NEXT_DATA__']",
+		Field: []*Field{
+			&Field{Title: "Title", Type: "string", Path: "props.page.title"},
+			&Field{Title: "Page", Type: "struct", Optional: true,
+				Field: []*Field{&Field{Title: "Total", Type: "int", Path: "total"}}},
+		}}
+	cf, err := f.Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"Title": "Jobs", "Page": nil}, cf.Retrieve(n))
+}
@@ -0,0 +1,689 @@
+// patterns
+package parser
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Expression of computed field, evaluated once other sub-fields of the struct are retrieved:
+//
+//	<Field title="Name" type="string"><Expr>First + " " + Last</Expr></Field>
+//	<Field title="SalaryMin" type="int"><Expr>number(find(Salary, '\$(\d+)k')) * 1000</Expr></Field>
+//	<Field title="Remote" type="bool"><Expr>match(Location, "(?i)remote") ? true : false</Expr></Field>
+//
+// operands are sibling field values (by title), XPath variables (url, host, URL named groups,
+// fields of enclosing structs), numbers, strings in double quotes (with Go escapes) or single quotes
+// (as is, handy for regexes), true, false and null;
+// operators by precedence, lowest first:
+//
+//	c ? a : b      conditional
+//	||             first truthy operand
+//	&&             first falsy operand
+//	== != < <= > >=    numbers are compared as numbers, other values as strings
+//	+ -            "+" concatenates if either operand is string
+//	* / %
+//	! -            unary
+//
+// arithmetic with null results in null; functions are listed in exprFuncs
+type CompiledExpr struct {
+	src  string
+	root *exprNode
+}
+
+type exprNode struct {
+	// "lit", "var", "call", "?" or operator
+	op   string
+	val  interface{}
+	name string
+	args []*exprNode
+}
+
+type exprFunc struct {
+	// number of arguments; max < 0 for any number
+	min, max int
+	call     func(args []interface{}) (interface{}, error)
+}
+
+var exprFuncs = map[string]exprFunc{
+	// number of characters of string, items of list
+	"len": {1, 1, func(a []interface{}) (interface{}, error) {
+		if list, ok := a[0].([]interface{}); ok {
+			return float64(len(list)), nil
+		}
+		return float64(utf8.RuneCountInString(exprText(a[0]))), nil
+	}},
+	"lower": {1, 1, stringFunc(strings.ToLower)},
+	"upper": {1, 1, stringFunc(strings.ToUpper)},
+	"trim":  {1, 1, stringFunc(strings.TrimSpace)},
+	// replace(s, old, new)
+	"replace": {3, 3, func(a []interface{}) (interface{}, error) {
+		if a[0] == nil {
+			return nil, nil
+		}
+		return strings.Replace(exprText(a[0]), exprText(a[1]), exprText(a[2]), -1), nil
+	}},
+	"concat": {1, -1, func(a []interface{}) (interface{}, error) {
+		res := ""
+		for _, v := range a {
+			res += exprText(v)
+		}
+		return res, nil
+	}},
+	// first non-empty value
+	"coalesce": {1, -1, func(a []interface{}) (interface{}, error) {
+		for _, v := range a {
+			if v != nil && v != "" {
+				return v, nil
+			}
+		}
+		return nil, nil
+	}},
+	// number of text like "$1,200.50"; null if there is no number
+	"number": {1, 1, func(a []interface{}) (interface{}, error) {
+		if a[0] == nil {
+			return nil, nil
+		}
+		if n, ok := exprNumber(a[0]); ok {
+			return n, nil
+		}
+		m := exprNumberRegexp.FindString(strings.Replace(exprText(a[0]), ",", "", -1))
+		if m == "" {
+			return nil, nil
+		}
+		return strconv.ParseFloat(m, 64)
+	}},
+	"round": {1, 1, numberFunc(math.Round)},
+	"floor": {1, 1, numberFunc(math.Floor)},
+	"ceil":  {1, 1, numberFunc(math.Ceil)},
+	"min":   {1, -1, func(a []interface{}) (interface{}, error) { return exprMinMax(a, -1) }},
+	"max":   {1, -1, func(a []interface{}) (interface{}, error) { return exprMinMax(a, 1) }},
+	// match(s, regex) is true if regex matches
+	"match": {2, 2, func(a []interface{}) (interface{}, error) {
+		r, err := exprRegexp(a[1])
+		if err != nil || a[0] == nil {
+			return false, err
+		}
+		return r.MatchString(exprText(a[0])), nil
+	}},
+	// find(s, regex) is first Submatch group (whole match if there are no groups); null if regex doesn't match
+	"find": {2, 2, func(a []interface{}) (interface{}, error) {
+		r, err := exprRegexp(a[1])
+		if err != nil || a[0] == nil {
+			return nil, err
+		}
+		m := r.FindStringSubmatch(exprText(a[0]))
+		if m == nil {
+			return nil, nil
+		}
+		return exprSubmatch(m), nil
+	}},
+	// findall(s, regex) lists every match (first Submatch group if any)
+	"findall": {2, 2, func(a []interface{}) (interface{}, error) {
+		r, err := exprRegexp(a[1])
+		if err != nil || a[0] == nil {
+			return nil, err
+		}
+		res := make([]interface{}, 0)
+		for _, m := range r.FindAllStringSubmatch(exprText(a[0]), -1) {
+			res = append(res, exprSubmatch(m))
+		}
+		return res, nil
+	}},
+	// sub(s, regex, replacement); replacement may refer groups like "$1"
+	"sub": {3, 3, func(a []interface{}) (interface{}, error) {
+		r, err := exprRegexp(a[1])
+		if err != nil || a[0] == nil {
+			return nil, err
+		}
+		return r.ReplaceAllString(exprText(a[0]), exprText(a[2])), nil
+	}},
+	"split": {2, 2, func(a []interface{}) (interface{}, error) {
+		if a[0] == nil {
+			return nil, nil
+		}
+		res := make([]interface{}, 0)
+		for _, s := range strings.Split(exprText(a[0]), exprText(a[1])) {
+			res = append(res, s)
+		}
+		return res, nil
+	}},
+	"join": {2, 2, func(a []interface{}) (interface{}, error) {
+		list, ok := a[0].([]interface{})
+		if !ok {
+			return a[0], nil
+		}
+		parts := make([]string, len(list))
+		for i, v := range list {
+			parts[i] = exprText(v)
+		}
+		return strings.Join(parts, exprText(a[1])), nil
+	}},
+}
+
+// functions taking string value; null stays null
+func stringFunc(fn func(string) string) func(a []interface{}) (interface{}, error) {
+	return func(a []interface{}) (interface{}, error) {
+		if a[0] == nil {
+			return nil, nil
+		}
+		return fn(exprText(a[0])), nil
+	}
+}
+
+func numberFunc(fn func(float64) float64) func(a []interface{}) (interface{}, error) {
+	return func(a []interface{}) (interface{}, error) {
+		if a[0] == nil {
+			return nil, nil
+		}
+		n, ok := exprNumber(a[0])
+		if !ok {
+			return nil, errors.New("not a number: " + exprText(a[0]))
+		}
+		return fn(n), nil
+	}
+}
+
+// smallest (sign < 0) or largest number of arguments, null ones are skipped; lists are compared by their items
+func exprMinMax(a []interface{}, sign float64) (interface{}, error) {
+	var res interface{}
+	for _, v := range a {
+		if list, ok := v.([]interface{}); ok {
+			m, err := exprMinMax(list, sign)
+			if err != nil {
+				return nil, err
+			}
+			v = m
+		}
+		if v == nil {
+			continue
+		}
+		n, ok := exprNumber(v)
+		if !ok {
+			return nil, errors.New("not a number: " + exprText(v))
+		}
+		if res == nil || (n-res.(float64))*sign > 0 {
+			res = n
+		}
+	}
+	return res, nil
+}
+
+// first Submatch group if there is one, whole match otherwise
+func exprSubmatch(m []string) string {
+	if len(m) > 1 {
+		return m[1]
+	}
+	return m[0]
+}
+
+var exprNumberRegexp = regexp.MustCompile(`-?\d+(\.\d+)?`)
+
+// regex argument of function: literals are compiled along with expression,
+// regexes made of values are compiled on every call
+func exprRegexp(v interface{}) (*regexp.Regexp, error) {
+	if r, ok := v.(*regexp.Regexp); ok {
+		return r, nil
+	}
+	return regexp.Compile(exprText(v))
+}
+
+func CompileExpr(src string) (*CompiledExpr, error) {
+	src = strings.TrimSpace(src)
+	if src == "" {
+		return nil, nil
+	}
+	tokens, err := exprTokens(src)
+	if err != nil {
+		return nil, errors.New(err.Error() + "\n Expr: " + src)
+	}
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseTernary()
+	if err == nil && p.pos < len(p.tokens) {
+		err = errors.New("unexpected " + p.tokens[p.pos])
+	}
+	if err != nil {
+		return nil, errors.New(err.Error() + "\n Expr: " + src)
+	}
+	return &CompiledExpr{src: src, root: root}, nil
+}
+
+func (e *CompiledExpr) String() string {
+	if e == nil {
+		return ""
+	}
+	return e.src
+}
+
+// names of values expression refers to
+func (e *CompiledExpr) Names() []string {
+	res := make([]string, 0)
+	if e != nil {
+		e.root.names(&res)
+	}
+	return res
+}
+
+func (n *exprNode) names(res *[]string) {
+	if n.op == "var" && !containsString(*res, n.name) {
+		*res = append(*res, n.name)
+	}
+	for _, arg := range n.args {
+		arg.names(res)
+	}
+}
+
+// Evaluates expression; "lookup" returns value by name
+func (e *CompiledExpr) Eval(lookup func(name string) interface{}) (interface{}, error) {
+	return e.root.eval(lookup)
+}
+
+func (n *exprNode) eval(lookup func(name string) interface{}) (interface{}, error) {
+	switch n.op {
+	case "lit":
+		return n.val, nil
+	case "var":
+		return lookup(n.name), nil
+	case "call":
+		args := make([]interface{}, len(n.args))
+		for i, arg := range n.args {
+			val, err := arg.eval(lookup)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = val
+		}
+		return exprFuncs[n.name].call(args)
+	case "?", "||", "&&":
+		// operands are evaluated lazily
+		cond, err := n.args[0].eval(lookup)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case n.op == "?" && exprTruthy(cond):
+			return n.args[1].eval(lookup)
+		case n.op == "?":
+			return n.args[2].eval(lookup)
+		case n.op == "||" && exprTruthy(cond), n.op == "&&" && !exprTruthy(cond):
+			return cond, nil
+		}
+		return n.args[1].eval(lookup)
+	}
+
+	vals := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		val, err := arg.eval(lookup)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = val
+	}
+
+	if len(vals) == 1 {
+		if n.op == "!" {
+			return !exprTruthy(vals[0]), nil
+		}
+		// unary minus
+		if vals[0] == nil {
+			return nil, nil
+		}
+		x, ok := exprNumber(vals[0])
+		if !ok {
+			return nil, errors.New("not a number: " + exprText(vals[0]))
+		}
+		return -x, nil
+	}
+
+	a, b := vals[0], vals[1]
+	switch n.op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return exprCompare(n.op, a, b), nil
+	case "+":
+		if _, ok := a.(string); ok {
+			return exprText(a) + exprText(b), nil
+		}
+		if _, ok := b.(string); ok {
+			return exprText(a) + exprText(b), nil
+		}
+	}
+
+	if a == nil || b == nil {
+		return nil, nil
+	}
+	x, ok := exprNumber(a)
+	if !ok {
+		return nil, errors.New("not a number: " + exprText(a))
+	}
+	y, ok := exprNumber(b)
+	if !ok {
+		return nil, errors.New("not a number: " + exprText(b))
+	}
+	switch n.op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	}
+	if y == 0 {
+		return nil, errors.New("division by zero")
+	}
+	if n.op == "%" {
+		return math.Mod(x, y), nil
+	}
+	return x / y, nil
+}
+
+func exprCompare(op string, a, b interface{}) bool {
+	var c int
+	x, xok := exprNumber(a)
+	y, yok := exprNumber(b)
+	_, xs := a.(string)
+	_, ys := b.(string)
+	switch {
+	case a == nil || b == nil:
+		// null equals null only
+		if op == "==" {
+			return a == nil && b == nil
+		}
+		return op == "!=" && (a != nil || b != nil)
+	case xok && yok && !xs && !ys:
+		if x < y {
+			c = -1
+		} else if x > y {
+			c = 1
+		}
+	default:
+		c = strings.Compare(exprText(a), exprText(b))
+	}
+
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+func exprTruthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case string:
+		return val != ""
+	case []interface{}:
+		return len(val) > 0
+	}
+	if n, ok := exprNumber(v); ok {
+		return n != 0
+	}
+	return true
+}
+
+// numeric value of numbers and numeric strings
+func exprNumber(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case int32:
+		return float64(val), true
+	case uint:
+		return float64(val), true
+	case uint64:
+		return float64(val), true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// text of value; null is empty string
+func exprText(v interface{}) string {
+	s, _ := variableValue(v)
+	return s
+}
+
+// splits expression into numbers, strings (kept quoted), names and operators
+func exprTokens(src string) ([]string, error) {
+	res := make([]string, 0)
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(src) && src[end] != c {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, errors.New("Unterminated string")
+			}
+			res = append(res, src[i:end+1])
+			i = end + 1
+		case c >= '0' && c <= '9' || c == '.':
+			end := i
+			for end < len(src) && (src[end] >= '0' && src[end] <= '9' || src[end] == '.') {
+				end++
+			}
+			res = append(res, src[i:end])
+			i = end
+		case isWordChar(c) && c != '$':
+			end := i
+			for end < len(src) && isWordChar(src[end]) && src[end] != '$' {
+				end++
+			}
+			res = append(res, src[i:end])
+			i = end
+		default:
+			if i+1 < len(src) {
+				switch src[i : i+2] {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					res = append(res, src[i:i+2])
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("+-*/%<>!?:(),", rune(c)) {
+				return nil, errors.New("unexpected " + string(c))
+			}
+			res = append(res, string(c))
+			i++
+		}
+	}
+	return res, nil
+}
+
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+// binary operators by precedence, lowest first
+var exprLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) expect(token string) error {
+	if p.peek() != token {
+		if p.peek() == "" {
+			return errors.New("expected " + token + " at the end")
+		}
+		return errors.New("expected " + token + " instead of " + p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *exprParser) parseTernary() (*exprNode, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil || p.peek() != "?" {
+		return cond, err
+	}
+	p.pos++
+	a, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(":"); err != nil {
+		return nil, err
+	}
+	b, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	return &exprNode{op: "?", args: []*exprNode{cond, a, b}}, nil
+}
+
+func (p *exprParser) parseBinary(level int) (*exprNode, error) {
+	if level == len(exprLevels) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for containsString(exprLevels[level], p.peek()) {
+		op := p.peek()
+		p.pos++
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &exprNode{op: op, args: []*exprNode{left, right}}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (*exprNode, error) {
+	if op := p.peek(); op == "!" || op == "-" {
+		p.pos++
+		arg, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNode{op: op, args: []*exprNode{arg}}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (*exprNode, error) {
+	token := p.peek()
+	if token == "" {
+		return nil, errors.New("unexpected end of expression")
+	}
+	p.pos++
+
+	switch c := token[0]; {
+	case token == "(":
+		node, err := p.parseTernary()
+		if err != nil {
+			return nil, err
+		}
+		return node, p.expect(")")
+	case c == '"':
+		s, err := strconv.Unquote(token)
+		if err != nil {
+			return nil, errors.New("bad string " + token)
+		}
+		return &exprNode{op: "lit", val: s}, nil
+	case c == '\'':
+		return &exprNode{op: "lit", val: token[1 : len(token)-1]}, nil
+	case c >= '0' && c <= '9' || c == '.':
+		n, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, errors.New("bad number " + token)
+		}
+		return &exprNode{op: "lit", val: n}, nil
+	case isWordChar(c):
+		switch token {
+		case "true":
+			return &exprNode{op: "lit", val: true}, nil
+		case "false":
+			return &exprNode{op: "lit", val: false}, nil
+		case "null":
+			return &exprNode{op: "lit", val: nil}, nil
+		}
+		if p.peek() == "(" {
+			return p.parseCall(token)
+		}
+		return &exprNode{op: "var", name: token}, nil
+	}
+	return nil, errors.New("unexpected " + token)
+}
+
+func (p *exprParser) parseCall(name string) (*exprNode, error) {
+	fn, ok := exprFuncs[name]
+	if !ok {
+		return nil, errors.New("unknown function " + name)
+	}
+	p.pos++
+
+	node := &exprNode{op: "call", name: name}
+	for p.peek() != ")" {
+		if len(node.args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseTernary()
+		if err != nil {
+			return nil, err
+		}
+		node.args = append(node.args, arg)
+	}
+	p.pos++
+
+	if len(node.args) < fn.min || fn.max >= 0 && len(node.args) > fn.max {
+		return nil, errors.New("wrong number of arguments of " + name)
+	}
+
+	// regex literals are compiled once
+	switch name {
+	case "match", "find", "findall", "sub":
+		if arg := node.args[1]; arg.op == "lit" {
+			r, err := regexp.Compile(exprText(arg.val))
+			if err != nil {
+				return nil, err
+			}
+			node.args[1] = &exprNode{op: "lit", val: r}
+		}
+	}
+	return node, nil
+}
@@ -0,0 +1,138 @@
+// patterns
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileExpr(t *testing.T) {
+	values := map[string]interface{}{
+		"First":    "Ada",
+		"Last":     "Lovelace",
+		"Salary":   "$80k–$120k",
+		"Count":    3,
+		"Rate":     1.5,
+		"Tags":     []interface{}{"go", "sql"},
+		"Salaries": []interface{}{100000, 75, "80"},
+	}
+	lookup := func(name string) interface{} { return values[name] }
+
+	var cases = []struct {
+		in  string
+		out interface{}
+	}{
+		{`First + " " + Last`, "Ada Lovelace"},
+		{`number(find(Salary, "\\$(\\d+)k")) * 1000`, 80000.0},
+		{`number(find(Salary, '\$(\d+)k')) * 1000`, 80000.0},
+		{`match(Last, lower(First) + "|^Love")`, true},
+		{`join(findall(Salary, '(\d+)k'), "-")`, "80-120"},
+		{`(Count + 1) * Rate - 2 % 3`, 4.0},
+		{`-Count + 10 / 4`, -0.5},
+		{`Count >= 3 && Rate < 2 ? "yes" : "no"`, "yes"},
+		{`Missing || "n/a"`, "n/a"},
+		{`Missing * 2`, nil},
+		{`Missing == null`, true},
+		{`"10" == 10 && "9" > 10`, true},
+		{`!match(Salary, "(?i)K")`, false},
+		{`upper(sub(First, "^(.)", "$1."))`, "A.DA"},
+		{`join(Tags, "|") + " " + len(Tags)`, "go|sql 2"},
+		{`max(Count, Rate, null)`, 3.0},
+		{`max(findall(Salary, '(\d+)k'))`, 120.0},
+		{`min(Salaries, 90)`, 75.0},
+		{`min(findall(First, '\d+'))`, nil},
+		{`coalesce(Missing, "", Last)`, "Lovelace"},
+		{`round(number("$1,234.56"))`, 1235.0},
+	}
+
+	for _, c := range cases {
+		e, err := CompileExpr(c.in)
+		if !assert.NoError(t, err, c.in) {
+			continue
+		}
+		val, err := e.Eval(lookup)
+		if assert.NoError(t, err, c.in) {
+			assert.Equal(t, c.out, val, c.in)
+		}
+	}
+
+	e, _ := CompileExpr(`Count / (Rate - 1.5)`)
+	_, err := e.Eval(lookup)
+	assert.EqualError(t, err, "division by zero")
+
+	// regexes made of values are compiled on evaluation
+	e, _ = CompileExpr(`match(Last, First + "(")`)
+	_, err = e.Eval(lookup)
+	assert.Error(t, err)
+
+	e, _ = CompileExpr(`First + Last * Count`)
+	assert.Equal(t, []string{"First", "Last", "Count"}, e.Names())
+
+	for _, bad := range []string{`First +`, `(First`, `exec("rm")`, `lower(First, Last)`, `match(First, "(")`, `First = 1`, `"abc`} {
+		_, err := CompileExpr(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+var exprPatternStr = `
+<Pattern mime="html">
+	<Field title="Job" type="struct">
+		<Path>//body</Path>
+		<Field title="SalaryMin" type="int">
+			<Expr>number(find(Salary, "\\$(\\d+)k")) * 1000</Expr>
+		</Field>
+		<Field title="SalaryMax" type="int" optional="true">
+			<Expr>number(find(Salary, "–\\$(\\d+)k")) * 1000</Expr>
+		</Field>
+		<Field title="Salary" type="string">
+			<Path>//span[@class='salary']</Path>
+		</Field>
+		<Field title="Name" type="string">
+			<Path>//h1</Path>
+		</Field>
+		<Field title="Slug" type="string">
+			<Expr>lower(sub(Name, "[^A-Za-z]+", "-")) + "-" + host</Expr>
+		</Field>
+		<Field title="Words" type="[]string">
+			<Expr>split(Name, " ")</Expr>
+		</Field>
+	</Field>
+</Pattern>
+`
+
+func TestRetrieve_computed(t *testing.T) {
+	p := NewPatterns(nil)
+	assert.NoError(t, p.LoadXml(p.Tree, []byte(exprPatternStr), "expr.xml"))
+
+	data, err := p.Apply("https://example.com/jobs/1", strings.NewReader(`<html><body>
+		<h1>Go Developer</h1><span class="salary">$80k–$120k</span>
+	</body></html>`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"expr.xml": map[string]interface{}{
+			"Job": map[string]interface{}{
+				"SalaryMin": 80000,
+				"SalaryMax": 120000,
+				"Salary":    "$80k–$120k",
+				"Name":      "Go Developer",
+				"Slug":      "go-developer-example.com",
+				"Words":     []interface{}{"Go", "Developer"},
+			},
+		},
+	}, data)
+
+	// values must be retrieved before expression
+	bad := strings.Replace(exprPatternStr, `"-" + host`, `"-" + Company`, 1)
+	assert.Error(t, p.LoadXml(p.Tree, []byte(bad), "bad.xml"))
+
+	for _, f := range []*Field{
+		&Field{Title: "Name", Type: "string", Expr: "First", Path: "//h1"},
+		&Field{Title: "Name", Type: "struct", Expr: "First"},
+		&Field{Title: "Name", Type: "string", Expr: "First +"},
+	} {
+		_, err := f.Compile()
+		assert.Error(t, err)
+	}
+}
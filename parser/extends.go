@@ -0,0 +1,111 @@
+// patterns
+package parser
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Reads pattern file and applies the chain of patterns it extends:
+//
+//	<Pattern extends="../_builtin/Body.xml">
+//		<URL>...</URL>
+//		<Field title="Body">
+//			<Field title="Company.Name"><Path>//h2</Path></Field>
+//		</Field>
+//	</Pattern>
+//
+// "extends" path is relative to the pattern file; fragments every file of the chain uses are those of its own
+// directory (see fragments.go), not of patterns extending it; "stack" is the chain of files being read, to detect cycles
+func readPattern(file string, dirs *fragmentDirs, stack []string) (*Map, error) {
+	file = filepath.Clean(file)
+	for i, prev := range stack {
+		if prev == file {
+			return nil, errors.New("Patterns inheritance cycle: " + strings.Join(append(stack[i:], file), " -> "))
+		}
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Map{}
+	switch {
+	case hasExt(file, "xml"):
+		err = m.UnmarshalXml(data)
+	case hasExt(file, "yaml"):
+		err = m.UnmarshalYaml(data)
+	default:
+		err = errors.New("Unsupported pattern file " + file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fragments, err := dirs.get(filepath.Dir(file))
+	if err != nil {
+		return nil, err
+	}
+	if m, err = fragments.resolve(m); err != nil {
+		return nil, errors.New("Failed to resolve fragments of " + file + ". " + err.Error())
+	}
+
+	if m.Extends == "" {
+		return m, nil
+	}
+
+	base, err := readPattern(filepath.Join(filepath.Dir(file), m.Extends), dirs, append(stack, file))
+	if err != nil {
+		return nil, err
+	}
+	if err := extendMap(base, m); err != nil {
+		return nil, errors.New("Pattern " + file + " extends " + m.Extends + ". " + err.Error())
+	}
+	return base, nil
+}
+
+// extends base pattern: URL rules, mime and storage are replaced if declared, fields are extended
+func extendMap(base, m *Map) error {
+	field := base.Field
+	override(base, m)
+	base.Field = field
+	base.Extends = ""
+
+	if m.Field == nil {
+		return nil
+	}
+	if base.Field == nil {
+		base.Field = m.Field
+		return nil
+	}
+	return extendField(base.Field, m.Field)
+}
+
+// Extends base field: attributes and elements declared by field override base ones (flags can only be set);
+// sub-fields are matched by title or title path, like "Company.Name", and extended the same way, new ones are appended
+func extendField(base, f *Field) error {
+	children := base.Field
+	override(base, f)
+	base.Field = children
+
+	for _, child := range f.Field {
+		target := base.FindField(child.Title)
+		if target == nil {
+			if strings.Contains(child.Title, ".") {
+				return errors.New("Field " + child.Title + " not found in " + base.Title)
+			}
+			base.Field = append(base.Field, child)
+			continue
+		}
+
+		parts := strings.Split(child.Title, ".")
+		if err := extendField(target, child); err != nil {
+			return err
+		}
+		target.Title = parts[len(parts)-1]
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// patterns
+package parser
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var basePatternStr = `<Pattern mime="html">
+	<Field title="Body" type="struct">
+		<Path>//div[@class='job']</Path>
+		<Field title="Title" type="string">
+			<Path>//h1</Path>
+		</Field>
+		<Field title="Company" type="struct" optional="true">
+			<Path>//div[@class='company']</Path>
+			<Field title="Name" type="string">
+				<Path>//b</Path>
+			</Field>
+		</Field>
+	</Field>
+</Pattern>`
+
+var extendsHtml = `<html><body>
+	<div class="job">
+		<h1>Go developer</h1>
+		<h2>Senior Go developer</h2>
+		<div class="company"><b>Acme</b><i>Initech</i></div>
+		<span class="salary">100000</span>
+	</div>
+</body></html>`
+
+func TestLoadExtends(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"_base/Body.xml": basePatternStr,
+		"a.com/Body.xml": `<Pattern extends="../_base/Body.xml">
+			<URL><Include>^https://a.com/</Include></URL>
+		</Pattern>`,
+		"b.com/Body.xml": `<Pattern extends="../_base/Body.xml">
+			<URL><Include>^https://b.com/</Include></URL>
+			<Field>
+				<Field title="Title"><Path>//h2</Path></Field>
+				<Field title="Company.Name"><Path>//i</Path></Field>
+				<Field title="Salary" type="int"><Path>//span[@class='salary']</Path></Field>
+			</Field>
+		</Pattern>`,
+		"c.com/Body.xml": `<Pattern extends="../b.com/Body.xml">
+			<URL><Include>^https://c.com/</Include></URL>
+			<Field><Field title="Company.Title"><Path>//i</Path></Field></Field>
+		</Pattern>`,
+		"d.com/Body.xml": `<Pattern extends="Body.xml"/>`,
+	})
+	defer os.RemoveAll(dir)
+
+	var logs bytes.Buffer
+	p := NewPatterns(log.New(&logs, "", 0))
+	assert.NoError(t, p.LoadTree(dir))
+	_, ok := (*p.Tree)["_base"]
+	assert.False(t, ok)
+
+	data, err := p.Apply("https://a.com/jobs/1", strings.NewReader(extendsHtml))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Title":   "Go developer",
+		"Company": map[string]interface{}{"Name": "Acme"},
+	}, data["a.com"].(map[string]interface{})["Body.xml"].(map[string]interface{})["Body"])
+
+	data, err = p.Apply("https://b.com/jobs/1", strings.NewReader(extendsHtml))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Title":   "Senior Go developer",
+		"Company": map[string]interface{}{"Name": "Initech"},
+		"Salary":  100000,
+	}, data["b.com"].(map[string]interface{})["Body.xml"].(map[string]interface{})["Body"])
+
+	assert.Contains(t, logs.String(), "Field Company.Title not found in Body")
+	assert.Contains(t, logs.String(), "Patterns inheritance cycle: "+dir+"/d.com/Body.xml -> "+dir+"/d.com/Body.xml")
+}
+
+func TestFindField(t *testing.T) {
+	m := &Map{}
+	assert.NoError(t, m.UnmarshalXml([]byte(basePatternStr)))
+	assert.Equal(t, "Name", m.Field.FindField("Company.Name").Title)
+	assert.Equal(t, "Title", m.Field.FindField("Title").Title)
+	assert.Nil(t, m.Field.FindField("Company.Title"))
+	assert.Nil(t, m.Field.FindField("Salary.Min"))
+
+	p := NewPatterns(nil)
+	assert.Error(t, p.LoadXml(p.Tree, []byte(`<Pattern extends="base.xml"/>`), "Body.xml"))
+}
+
+func TestLoadExtends_fragments(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"_base/_fragments.xml": `<Fragments>
+			<Field title="Title" type="string"><Path>//h1</Path></Field>
+		</Fragments>`,
+		"_base/Body.xml": `<Pattern mime="html">
+			<Field title="Body" type="struct">
+				<Path>//div[@class='job']</Path>
+				<Field title="Title" use="Title"/>
+			</Field>
+		</Pattern>`,
+		// base fragments are resolved in base directory, whatever extends it
+		"a.com/_fragments.xml": `<Fragments>
+			<Field title="Title" type="string"><Path>//h2</Path></Field>
+		</Fragments>`,
+		"a.com/Body.xml": `<Pattern extends="../_base/Body.xml">
+			<URL><Include>^https://a.com/</Include></URL>
+		</Pattern>`,
+		"b.com/Body.xml": `<Pattern extends="../_base/Body.xml">
+			<URL><Include>^https://b.com/</Include></URL>
+			<Field><Field title="Salary" use="Title"/></Field>
+		</Pattern>`,
+	})
+	defer os.RemoveAll(dir)
+
+	var logs bytes.Buffer
+	p := NewPatterns(log.New(&logs, "", 0))
+	assert.NoError(t, p.LoadTree(dir))
+
+	data, err := p.Apply("https://a.com/jobs/1", strings.NewReader(extendsHtml))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"Title": "Go developer"},
+		data["a.com"].(map[string]interface{})["Body.xml"].(map[string]interface{})["Body"])
+
+	// fragments of base directory aren't available to patterns extending it
+	assert.Contains(t, logs.String(), dir+`/b.com/Body.xml. Field Salary: unknown Field fragment "Title"`)
+}
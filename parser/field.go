@@ -7,6 +7,7 @@ import (
 	//	"fmt"
 	"io"
 	"reflect"
+	"regexp"
 	"strings"
 
 	"github.com/antchfx/xpath"
@@ -19,13 +20,17 @@ type Field struct {
 	// field title
 	Title string `xml:"title,attr"`
 
-	// field type: int, string, float64, struct, html
+	// field type: int, int64, uint, float64, bool, string, time, date, url, struct, html;
+	// structured data: jsonld, microdata, opengraph (see structured.go);
+	// slices are declared with "[]" prefix: []string, []struct, [][]string
 	Type string `xml:"type,attr"`
 
-	// xpath expression to find field
+	// xpath expression to find field (both for mime="html" and mime="xml" patterns);
+	// JSONPath for mime="json" patterns; optional for structured data types
 	Path string
 
-	// data filter or transformaion based on regex expressions; see rules.go
+	// data filter or transformaion based on regex expressions; see rules.go;
+	// struct made of named Submatch groups, like (?P<City>[^,]+), (?P<State>\w+), if type is struct
 	Data *RegexRules
 
 	// data filter based on xpath expressions
@@ -34,6 +39,27 @@ type Field struct {
 	// optional field
 	Optional bool `xml:"optional,attr,omitempty"`
 
+	// what happens if required field is missing: drop (default), warn or fail-document; see defaults.go
+	Required string `xml:"required,attr,omitempty"`
+
+	// value used if field is missing
+	Default string `xml:"default,attr,omitempty"`
+
+	// leave missing field out of struct instead of null value
+	OmitEmpty bool `xml:"omitempty,attr,omitempty"`
+
+	// value constraints checked after conversion; see validate.go
+	Min       string `xml:"min,attr,omitempty"`
+	Max       string `xml:"max,attr,omitempty"`
+	MinLength int    `xml:"minlength,attr,omitempty"`
+	MaxLength int    `xml:"maxlength,attr,omitempty"`
+	Enum      string `xml:"enum,attr,omitempty"`
+	Match     string `xml:"match,attr,omitempty"`
+	Format    string `xml:"format,attr,omitempty"`
+
+	// what happens to values violating constraints: drop (default), null or report
+	OnInvalid string `xml:"oninvalid,attr,omitempty"`
+
 	// deprecated
 	DontStore bool `xml:"dontstore,attr,omitempty"`
 
@@ -46,17 +72,92 @@ type Field struct {
 	// preserve HTML attributes (only works if field type="html")
 	Attr bool `xml:"attr,attr,omitempty"`
 
+	// strip thousands separators, currency symbols and spaces off numbers, e.g. "$ 1,299"
+	Lenient bool `xml:"lenient,attr,omitempty"`
+
+	// decimal separator for numbers: "." (default) or ","
+	Decimal string `xml:"decimal,attr,omitempty"`
+
+	// time layouts (one per line) for "time" and "date" types, like: Jan 2, 2006
+	Layout string
+
+	// timezone for "time" and "date" types, like: America/New_York; UTC by default
+	Timezone string `xml:"timezone,attr,omitempty"`
+
+	// URL normalization for "url" type, comma separated: strip-fragment, sort-query, drop-tracking
+	Normalize string `xml:"normalize,attr,omitempty"`
+
+	// value transformations applied before conversion, one per line, like: trim, replace "," ""; see transform.go
+	Transform string
+
+	// expression value of computed field is taken from instead of document, like: First + " " + Last;
+	// evaluated after other sub-fields of the struct; see expr.go
+	Expr string
+
+	// schema.org types filter for structured data types, comma separated, like: JobPosting
+	Schema string `xml:"schema,attr,omitempty"`
+
+	// "json": struct is parsed from JSON embedded into nodes found by Path (usually <script>);
+	// sub-fields paths are JSONPath then; see embedded.go
+	Source string `xml:"source,attr,omitempty"`
+
+	// JavaScript variable embedded JSON is assigned to, like: window.__INITIAL_STATE__
+	Var string `xml:"var,attr,omitempty"`
+
+	// named group of pattern URL Include regex value is taken from instead of document, like: JobId
+	// for ^https://example.com/jobs/(?P<JobId>\d+)
+	Capture string `xml:"capture,attr,omitempty"`
+
+	// fragment this field is based on; see fragments.go
+	Use string `xml:"use,attr,omitempty"`
+
+	// fragment name (only in fragments file); title is used if empty
+	Name string `xml:"name,attr,omitempty"`
+
 	// sub-fields declaration
 	Field []*Field
+
+	// variants of struct sub-fields; see switch.go
+	Switch *Switch
 }
 
 type CompiledField struct {
-	title    string
-	dataType *Type
-	path     []*xpath.Expr //[]string //[]*xmlpath.Path
-	xdata    *CompiledXpathRules
-	data     *CompiledRegexRules
-	parent   *CompiledField
+	title     string
+	dataType  *Type
+	path      []*xpath.Expr //[]string //[]*xmlpath.Path
+	jsonPath  []*JsonPath
+	xdata     *CompiledXpathRules
+	data      *CompiledRegexRules
+	transform *CompiledTransform
+	parent    *CompiledField
+
+	// "json" for struct parsed from embedded JSON
+	source     string
+	assignment *regexp.Regexp
+
+	// struct made of named Submatch groups
+	grouped bool
+
+	// URL named group value is taken from
+	capture string
+
+	// expression of computed field
+	expr *CompiledExpr
+
+	// expressions of path with variables, bound on evaluation (path has nil for them); see variables.go
+	templates []string
+
+	// default value, required level and omitempty option; see defaults.go
+	def       string
+	required  string
+	omitEmpty bool
+
+	// value constraints; see validate.go
+	constraints *constraints
+
+	// struct variants and title their names are stored to
+	variantTitle string
+	variants     []*compiledVariant
 
 	optional  bool `xml:"optional,attr,omitempty"`
 	dontStore bool `xml:"dontstore,attr,omitempty"`
@@ -84,11 +185,15 @@ func (f *Field) FindChildField(name string) *Field {
 	return nil
 }
 
+// finds sub-field by title path, like: "Company.Name"; nil if there is no such field
 func (f *Field) FindField(addr string) *Field {
 	parts := strings.Split(addr, ".")
 	result := f
 	for _, p := range parts {
-		result = f.FindChildField(p)
+		if result == nil {
+			return nil
+		}
+		result = result.FindChildField(p)
 	}
 	return result
 }
@@ -102,10 +207,19 @@ func (f *CompiledField) setParent() {
 }
 
 func (f *Field) Compile() (*CompiledField, error) {
+	return f.compile("html")
+}
+
+// compiles field for pattern of given mime
+func (f *Field) compile(mime string) (*CompiledField, error) {
 	c := &CompiledField{}
 
 	var err error
-	c.path, err = cdataToPaths(f.Path)
+	if mime == "json" {
+		c.jsonPath, err = cdataToJsonPaths(f.Path)
+	} else {
+		c.path, c.templates, err = cdataToFieldPaths(f.Path)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -120,9 +234,38 @@ func (f *Field) Compile() (*CompiledField, error) {
 		return nil, err
 	}
 
+	c.transform, err = CompileTransform(f.Transform)
+	if err != nil {
+		return nil, errors.New("Failed to compile " + f.Title + ". " + err.Error())
+	}
+
+	c.expr, err = CompileExpr(f.Expr)
+	if err != nil {
+		return nil, errors.New("Failed to compile " + f.Title + ". " + err.Error())
+	}
+
+	// embedded JSON is addressed with JSONPath
+	childMime := mime
+	switch f.Source {
+	case "":
+		if f.Var != "" {
+			return nil, errors.New("Failed to compile " + f.Title + ". Var is only allowed for source=\"json\"")
+		}
+	case "json":
+		if mime != "html" {
+			return nil, errors.New("Failed to compile " + f.Title + ". Source \"json\" is only supported by html patterns")
+		}
+		if len(c.path) == 0 {
+			return nil, errors.New("Failed to compile " + f.Title + ". Source \"json\" requires Path")
+		}
+		childMime = "json"
+	default:
+		return nil, errors.New("Failed to compile " + f.Title + ". Unrecognized source " + f.Source)
+	}
+
 	c.field = make([]*CompiledField, 0)
 	for _, field := range f.Field {
-		compiledField, err := field.Compile()
+		compiledField, err := field.compile(childMime)
 		if err != nil {
 			return nil, err
 		}
@@ -135,16 +278,111 @@ func (f *Field) Compile() (*CompiledField, error) {
 
 	c.dataType, err = CompileType(f.Type)
 	if err != nil {
+		return nil, errors.New("Failed to compile " + f.Title + ". " + err.Error())
+	}
+
+	err = c.dataType.base().SetFormat(f.Lenient, f.Decimal)
+	if err != nil {
+		return nil, errors.New("Failed to compile " + f.Title + ". " + err.Error())
+	}
+
+	err = c.dataType.base().SetLayout(f.Layout, f.Timezone)
+	if err != nil {
+		return nil, errors.New("Failed to compile " + f.Title + ". " + err.Error())
+	}
+
+	err = c.dataType.base().SetNormalize(f.Normalize)
+	if err != nil {
+		return nil, errors.New("Failed to compile " + f.Title + ". " + err.Error())
+	}
+
+	err = c.dataType.base().SetSchema(f.Schema)
+	if err != nil {
+		return nil, errors.New("Failed to compile " + f.Title + ". " + err.Error())
+	}
+
+	if f.Source != "" && c.dataType.base().kind != reflect.Struct {
+		return nil, errors.New("Failed to compile " + f.Title + ". Source \"" + f.Source + "\" requires struct type")
+	}
+
+	// struct made of named Submatch groups: sub-fields only declare group types
+	if groups := c.data.Groups(); len(groups) > 0 && f.Source == "" && c.dataType.base().kind == reflect.Struct {
+		c.grouped = true
+		for _, child := range f.Field {
+			if child.Path != "" {
+				return nil, errors.New("Failed to compile " + f.Title + ". Sub-field " + child.Title + " of Submatch groups struct can't have Path")
+			}
+			if !containsString(groups, child.Title) {
+				return nil, errors.New("Failed to compile " + f.Title + ". Sub-field " + child.Title + " doesn't match any Submatch group")
+			}
+		}
+	}
+
+	if structured := c.dataType.base().structured; structured != "" {
+		if mime != "html" {
+			return nil, errors.New("Failed to compile " + f.Title + ". Type " + structured + " is only supported by html patterns")
+		}
+		if len(f.Field) > 0 {
+			return nil, errors.New("Failed to compile " + f.Title + ". Type " + structured + " can't have sub-fields")
+		}
+	}
+
+	if f.Capture != "" {
+		if f.Path != "" || len(f.Field) > 0 || f.Source != "" {
+			return nil, errors.New("Failed to compile " + f.Title + ". Capture field can't have Path, source or sub-fields")
+		}
+		if kind := c.dataType.base().kind; kind == reflect.Struct || kind == reflect.Map {
+			return nil, errors.New("Failed to compile " + f.Title + ". Capture isn't supported for " + c.dataType.String() + " type")
+		}
+	}
+
+	if err = f.compileDefaults(c); err != nil {
+		return nil, err
+	}
+
+	if err = f.compileConstraints(c); err != nil {
 		return nil, err
 	}
 
+	if f.Switch != nil {
+		if c.dataType.base().kind != reflect.Struct || c.grouped || f.Source != "" {
+			return nil, errors.New("Failed to compile " + f.Title + ". Switch is only supported by struct type")
+		}
+		c.variantTitle, c.variants, err = f.Switch.compile(f, childMime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.expr != nil {
+		if f.Path != "" || len(f.Field) > 0 || f.Source != "" || f.Capture != "" {
+			return nil, errors.New("Failed to compile " + f.Title + ". Computed field can't have Path, source, capture or sub-fields")
+		}
+		if kind := c.dataType.base().kind; kind == reflect.Struct || kind == reflect.Map {
+			return nil, errors.New("Failed to compile " + f.Title + ". Expr isn't supported for " + c.dataType.String() + " type")
+		}
+	}
+
 	c.title = f.Title
+	c.capture = f.Capture
+	c.source = f.Source
+	c.assignment = assignmentRegexp(f.Var)
 	c.unique = f.Unique
 	c.dontStore = f.DontStore
-	c.multiple = f.Multiple
+	// slice types imply multiple values
+	c.multiple = f.Multiple || c.dataType.kind == reflect.Slice
 	c.optional = f.Optional
 	c.attr = f.Attr
 
+	if c.transform != nil {
+		if kind := c.dataType.base().kind; kind == reflect.Struct || kind == reflect.Map {
+			return nil, errors.New("Failed to compile " + f.Title + ". Transform isn't supported for " + c.dataType.String() + " type")
+		}
+		if c.transform.splits() && !c.multiple {
+			return nil, errors.New("Failed to compile " + f.Title + ". Transform split requires multiple field or join")
+		}
+	}
+
 	return c, nil
 }
 
@@ -157,202 +395,474 @@ func (f *CompiledField) RelativePath() string {
 	}
 }
 
-func (f *CompiledField) Retrieve(root *html.Node) (result interface{}) {
-	// is "f" has no children and so is simple type, like: int, string, float64, etc.
-	if f.dataType.kind != reflect.Struct {
+func (f *CompiledField) Retrieve(root *html.Node) interface{} {
+	return f.retrieve(nil, root, nil)
+}
+
+// Retrieves field within context of the document; context could be nil
+func (f *CompiledField) RetrieveContext(ctx *Context, root *html.Node) interface{} {
+	return f.retrieve(ctx, root, nil)
+}
+
+// Same as RetrieveContext, but also reports which paths matched and why values were dropped
+func (f *CompiledField) RetrieveWithReport(ctx *Context, root *html.Node) (interface{}, *Report) {
+	report := newReport(f.title)
+	return f.retrieve(ctx, root, report), report
+}
+
+// report could be nil
+func (f *CompiledField) retrieve(ctx *Context, root *html.Node, report *Report) (result interface{}) {
+	// element type of slices, type itself otherwise
+	dataType := f.dataType.base()
+
+	// "[][]string" like types group values by node found
+	nested := f.dataType.depth() > 1
+
+	if f.capture != "" {
+		return f.retrieveCapture(ctx, report)
+	}
+
+	if f.expr != nil {
+		return f.retrieveComputed(ctx, nil, report)
+	}
+
+	if dataType.structured != "" {
+		return f.retrieveStructured(ctx, root, report)
+	}
+
+	if f.source == "json" {
+		return f.retrieveEmbedded(ctx, root, report)
+	}
+
+	// is "f" has no children and so is simple type, like: int, string, float64, etc.;
+	// structs of named Submatch groups are found the same way
+	if dataType.kind != reflect.Struct || f.grouped {
 		// check every Path provided
-		for _, query := range f.path {
+		for _, query := range f.paths(ctx, report) {
 			if f.multiple {
-				if f.dataType.isHtml {
-					res := make([]interface{}, 0)
-					var err error
+				res := make([]interface{}, 0)
+				hits := 0
+				if dataType.isHtml {
 					htmlquery.FindEach(root, query.String(), func(n int, next *html.Node) {
-						// test include/exclude
-						if f.xdata.Test(htmlquery.CreateXPathNavigator(next)) {
-							// clean off unnecessary nodes
-							next = f.xdata.Clean(next)
-
-							if next != nil {
-								var buf bytes.Buffer
-								w := io.Writer(&buf)
-
-								if f.attr {
-									err = html.Render(w, next)
-								} else {
-									err = Render(w, next)
-								}
-								if err != nil {
-									// cant render
-									//fmt.Println(err)
-								}
-
-								val, err := ByteToKind(f.dataType.kind, buf.Bytes())
-								if err != nil {
-									// cant convert
-									//fmt.Println(err)
-								} else {
-									if f.unique {
-										unique := true
-										for _, v := range res {
-											// not sure if it will work???
-											if v == val {
-												unique = false
-											}
-										}
-										if unique {
-											res = append(res, val)
-										}
-									} else {
-										res = append(res, val)
-									}
-								}
+						hits++
+						// values found within current node only
+						group := make([]interface{}, 0)
+						for _, val := range f.htmlValues(ctx, next, report) {
+							if nested {
+								group = f.appendValue(group, val)
+							} else {
+								res = f.appendValue(res, val)
 							}
 						}
+						if nested && len(group) > 0 {
+							res = append(res, group)
+						}
 					})
-					result = interface{}(res)
 				} else {
-					res := make([]interface{}, 0)
 					iter := query.Evaluate(htmlquery.CreateXPathNavigator(root)).(*xpath.NodeIterator)
 					for iter.MoveNext() {
+						hits++
 						// try to find each context
 						bts := []byte(iter.Current().Value())
+						if !f.testData(bts, report) {
+							continue
+						}
 
-						test := f.data.Test(bts)
-						if test {
-							found := f.data.FindMultiple(bts)
-							for _, nextVal := range found {
-								cut := f.data.Clean(nextVal)
-								val, err := ByteToKind(f.dataType.kind, cut)
-								if err != nil {
-									// cannot convert
-									//fmt.Println(err)
-								} else {
-									if f.unique {
-										unique := true
-										for _, v := range res {
-											// not sure if it will work???
-											if v == val {
-												unique = false
-											}
-										}
-										if unique {
-											res = append(res, val)
-										}
-									} else {
-										res = append(res, val)
-									}
-								}
+						// values found within current node only
+						group := make([]interface{}, 0)
+						for _, val := range f.matchValues(ctx, bts, report) {
+							if nested {
+								group = f.appendValue(group, val)
+							} else {
+								res = f.appendValue(res, val)
 							}
 						}
+						if nested && len(group) > 0 {
+							res = append(res, group)
+						}
 					}
-					result = interface{}(res)
 				}
-
+				report.hit(query.String(), hits)
+				result = interface{}(res)
 			} else {
-				var err error
-				if f.dataType.isHtml {
+				if dataType.isHtml {
 					singleNode := htmlquery.FindOne(root, query.String())
-
-					// test include/exclude
-					if f.xdata.Test(htmlquery.CreateXPathNavigator(singleNode)) {
-						// clean off unnecessary nodes
-						singleNode = f.xdata.Clean(singleNode)
-
-						if singleNode != nil {
-							var buf bytes.Buffer
-							w := io.Writer(&buf)
-
-							if f.attr {
-								err = html.Render(w, singleNode)
-							} else {
-								err = Render(w, singleNode)
-							}
-							if err != nil {
-								// cant render
-								//fmt.Println(err)
-							}
-
-							result, err = ByteToKind(f.dataType.kind, buf.Bytes())
-							if err != nil {
-								// cant convert
-								//fmt.Println(err)
-							}
-						}
+					if singleNode != nil {
+						report.hit(query.String(), 1)
+						result = f.htmlValue(ctx, singleNode, report)
+					} else {
+						report.hit(query.String(), 0)
 					}
 				} else {
 					// create iterator from "query" xpath within "root"
 					iter := query.Evaluate(htmlquery.CreateXPathNavigator(root)).(*xpath.NodeIterator)
 					if iter.MoveNext() {
 						val := []byte(iter.Current().Value())
-						test := f.data.Test(val)
-						if test {
-							cut := f.data.Clean(val)
-							found := f.data.FindOne(cut)
-							result, err = ByteToKind(f.dataType.kind, found)
-							if err != nil {
-								// cant convert
-								//fmt.Println(err)
+						if f.testData(val, report) {
+							result = f.matchValue(ctx, val, report)
+						}
+
+						if report != nil {
+							// count the rest of nodes found
+							hits := 1
+							for iter.MoveNext() {
+								hits++
 							}
+							report.hit(query.String(), hits)
 						}
+					} else {
+						report.hit(query.String(), 0)
 					}
 				}
 			}
 
 			// exit if at least 1 value found for Path
 			if result != nil {
+				report.matched(query.String(), countValues(result))
 				return result
 			}
 		}
 	} else {
+		// only one path available works for struct
+		paths := f.paths(ctx, report)
+		if len(paths) == 0 {
+			return nil
+		}
+		query := paths[0].String()
 		if f.multiple {
 			res := make([]interface{}, 0)
-
-			// only one path available works for struct
-			htmlquery.FindEach(root, f.path[0].String(), func(N int, subRootIter *html.Node) {
+			hits := 0
+			htmlquery.FindEach(root, query, func(N int, subRootIter *html.Node) {
+				hits++
 				// try to find each context
-				val := make(map[string]interface{})
-				for _, child_field := range f.field {
-					r := child_field.Retrieve(subRootIter)
-
-					if r == nil && !child_field.optional {
-						//result = nil
-						val = nil
-						break
-					}
-
-					val[child_field.title] = r
-				}
-				if val != nil {
+				if val := f.retrieveStruct(ctx, subRootIter, report); val != nil {
 					res = append(res, val)
 				}
 			})
+			report.hit(query, hits)
+			if hits == 0 {
+				report.add(DiagNoMatch, "path matched no nodes", nil)
+			}
 
 			result = res
+			report.matched(query, len(res))
 		} else {
-			val := make(map[string]interface{})
-
-			subRoot := htmlquery.FindOne(root, f.path[0].String())
-			//iter := f.path[0].Evaluate(root).(*xpath.NodeIterator)
-			//if iter.MoveNext() {
+			subRoot := htmlquery.FindOne(root, query)
 			if subRoot != nil {
-				for _, child_field := range f.field {
-					//r := child_field.Retrieve(iter.Node())
-					r := child_field.Retrieve(subRoot)
-
-					if r == nil && !child_field.optional {
-						//result = nil
-						val = nil
-						break
-					}
-
-					val[child_field.title] = r
-				}
-				if val != nil {
+				report.hit(query, 1)
+				if val := f.retrieveStruct(ctx, subRoot, report); val != nil {
 					result = val
+					report.matched(query, 1)
 				}
+			} else {
+				report.hit(query, 0)
+				report.add(DiagNoMatch, "path matched no nodes", nil)
 			}
 		}
 	}
 
 	return result
 }
+
+// fields with computed ones moved to the end
+func computedLast(fields []*CompiledField) []*CompiledField {
+	res := make([]*CompiledField, 0, len(fields))
+	for _, child := range fields {
+		if child.expr == nil {
+			res = append(res, child)
+		}
+	}
+	for _, child := range fields {
+		if child.expr != nil {
+			res = append(res, child)
+		}
+	}
+	return res
+}
+
+// evaluates expression of computed field over values of its siblings and context variables
+func (f *CompiledField) retrieveComputed(ctx *Context, siblings map[string]interface{}, report *Report) interface{} {
+	vars := ctx.variables()
+	val, err := f.expr.Eval(func(name string) interface{} {
+		if v, ok := siblings[name]; ok {
+			return v
+		}
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return nil
+	})
+	if err != nil {
+		report.add(DiagConversion, err.Error(), []byte(f.expr.String()))
+		return nil
+	}
+	if val == nil {
+		report.add(DiagNoMatch, "expression value is null", []byte(f.expr.String()))
+		return nil
+	}
+
+	// lists make multiple values
+	items, ok := val.([]interface{})
+	if !ok {
+		items = []interface{}{val}
+	}
+	res := make([]interface{}, 0)
+	for _, item := range items {
+		bts := []byte(exprText(item))
+		if item == nil || !f.testData(bts, report) {
+			continue
+		}
+		if !f.multiple {
+			return f.matchValue(ctx, bts, report)
+		}
+		for _, v := range f.matchValues(ctx, bts, report) {
+			res = f.appendValue(res, v)
+		}
+	}
+	if !f.multiple {
+		return nil
+	}
+	return res
+}
+
+// converts URL named group value to field type
+func (f *CompiledField) retrieveCapture(ctx *Context, report *Report) interface{} {
+	val, ok := ctx.capture(f.capture)
+	if !ok {
+		report.add(DiagNoMatch, "URL group "+f.capture+" not captured", nil)
+		return nil
+	}
+	if !f.testData([]byte(val), report) {
+		return nil
+	}
+	if f.multiple {
+		return f.matchValues(ctx, []byte(val), report)
+	}
+	return f.matchValue(ctx, []byte(val), report)
+}
+
+// checks every capture field refers to one of URL named groups
+func (f *CompiledField) checkCaptures(groups []string) error {
+	if f.capture != "" && !containsString(groups, f.capture) {
+		return errors.New("Field " + f.title + " captures " + f.capture + ", which isn't named group of URL Include")
+	}
+	for _, child := range f.allFields() {
+		if err := child.checkCaptures(groups); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retrieves sub-fields within node; returns nil if any required sub-field is missing
+func (f *CompiledField) retrieveStruct(ctx *Context, node *html.Node, report *Report) map[string]interface{} {
+	v, ok := f.variant(htmlquery.CreateXPathNavigator(node), func() []byte { return []byte(htmlquery.InnerText(node)) }, report)
+	if !ok {
+		return nil
+	}
+	return f.collectStruct(ctx, report, v, func(ctx *Context, child *CompiledField, childReport *Report) interface{} {
+		return child.retrieve(ctx, node, childReport)
+	})
+}
+
+// builds struct from sub-fields values; returns nil if any required sub-field is missing (see defaults.go)
+// every value is available as variable to sub-fields retrieved after it; computed sub-fields are evaluated last
+func (f *CompiledField) collectStruct(ctx *Context, report *Report, v *compiledVariant, retrieveChild func(ctx *Context, child *CompiledField, childReport *Report) interface{}) map[string]interface{} {
+	ctx = ctx.withVariables()
+	val := make(map[string]interface{})
+	for _, child_field := range computedLast(f.fields(v)) {
+		childReport := report.child(child_field.title)
+		nulled := false
+		childCtx := ctx.withNulled(&nulled)
+		var r interface{}
+		if child_field.expr != nil {
+			r = child_field.retrieveComputed(childCtx, val, childReport)
+		} else {
+			r = retrieveChild(childCtx, child_field, childReport)
+		}
+
+		// invalid value replaced with null (oninvalid="null") is kept as it is
+		if isEmptyValue(r) && !nulled {
+			if def := child_field.defaultValue(childCtx, childReport); def != nil {
+				r = def
+			}
+		}
+
+		if r == nil && !nulled && !f.missing(ctx, child_field, report) {
+			return nil
+		}
+
+		if child_field.omitEmpty && isEmptyValue(r) {
+			continue
+		}
+		val[child_field.title] = r
+		ctx.bind(child_field.title, r)
+	}
+	if v != nil {
+		val[f.variantTitle] = v.name
+	}
+	return val
+}
+
+// renders node to HTML and converts it to field type; Transform could split it to several values
+func (f *CompiledField) htmlValues(ctx *Context, node *html.Node, report *Report) []interface{} {
+	// test include/exclude
+	if ok, reason := f.xdata.Check(htmlquery.CreateXPathNavigator(node)); !ok {
+		report.add(DiagRejected, reason, []byte(htmlquery.InnerText(node)))
+		return nil
+	}
+
+	// clean off unnecessary nodes
+	node = f.xdata.Clean(node)
+	if node == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	w := io.Writer(&buf)
+
+	var err error
+	if f.attr {
+		err = html.Render(w, node)
+	} else {
+		err = Render(w, node)
+	}
+	if err != nil {
+		// cant render
+		report.add(DiagRender, err.Error(), nil)
+	}
+
+	return f.transformValues(ctx, buf.Bytes(), report)
+}
+
+// same as htmlValues for fields of single value
+func (f *CompiledField) htmlValue(ctx *Context, node *html.Node, report *Report) interface{} {
+	if vals := f.htmlValues(ctx, node, report); len(vals) > 0 {
+		return vals[0]
+	}
+	return nil
+}
+
+// test include/exclude regex rules
+func (f *CompiledField) testData(data []byte, report *Report) bool {
+	ok, reason := f.data.Check(data)
+	if !ok {
+		report.add(DiagRejected, reason, data)
+	}
+	return ok
+}
+
+// finds Submatch in data and converts it to field type
+func (f *CompiledField) matchValue(ctx *Context, data []byte, report *Report) interface{} {
+	if f.grouped {
+		return f.groupStruct(ctx, f.data.FindGroups(f.data.Clean(data)), report)
+	}
+	cut := f.data.Clean(data)
+	return f.transformValue(ctx, f.data.FindOne(cut), report)
+}
+
+// finds every Submatch in data and converts them to field type; values which can't be converted are skipped
+func (f *CompiledField) matchValues(ctx *Context, data []byte, report *Report) []interface{} {
+	res := make([]interface{}, 0)
+	if f.grouped {
+		for _, groups := range f.data.FindAllGroups(f.data.Clean(data)) {
+			if val := f.groupStruct(ctx, groups, report); val != nil {
+				res = append(res, val)
+			}
+		}
+		return res
+	}
+	for _, nextVal := range f.data.FindMultiple(data) {
+		cut := f.data.Clean(nextVal)
+		res = append(res, f.transformValues(ctx, cut, report)...)
+	}
+	return res
+}
+
+// builds struct of named Submatch groups (Remove rules are applied before matching);
+// sub-fields titled after groups set their types, other groups are strings
+func (f *CompiledField) groupStruct(ctx *Context, groups map[string][]byte, report *Report) interface{} {
+	if groups == nil {
+		return nil
+	}
+
+	val := f.collectStruct(ctx, report, nil, func(ctx *Context, child *CompiledField, childReport *Report) interface{} {
+		data, ok := groups[child.title]
+		if !ok || !child.testData(data, childReport) {
+			return nil
+		}
+		if child.multiple {
+			return child.matchValues(ctx, data, childReport)
+		}
+		return child.matchValue(ctx, data, childReport)
+	})
+	if val == nil {
+		return nil
+	}
+
+	for _, name := range f.data.Groups() {
+		if _, ok := val[name]; ok {
+			continue
+		}
+		if data, ok := groups[name]; ok {
+			val[name] = string(data)
+		} else {
+			val[name] = nil
+		}
+	}
+	return val
+}
+
+// applies Transform to data and converts results to field type
+func (f *CompiledField) transformValues(ctx *Context, data []byte, report *Report) []interface{} {
+	res := make([]interface{}, 0)
+	for _, next := range f.transform.Apply(data) {
+		if val := f.convertValue(ctx, next, report); val != nil {
+			res = append(res, val)
+		}
+	}
+	return res
+}
+
+// same as transformValues for fields of single value; returns nil if data can't be converted
+func (f *CompiledField) transformValue(ctx *Context, data []byte, report *Report) interface{} {
+	if vals := f.transformValues(ctx, data, report); len(vals) > 0 {
+		return vals[0]
+	}
+	return nil
+}
+
+// converts data to field type; returns nil if it can't
+func (f *CompiledField) convertValue(ctx *Context, data []byte, report *Report) interface{} {
+	val, err := f.dataType.base().convert(ctx, data)
+	if err != nil {
+		// cant convert
+		report.add(DiagConversion, err.Error(), data)
+		return nil
+	}
+	return f.validate(ctx, val, data, report)
+}
+
+// appends value unless field is unique and value is already there
+func (f *CompiledField) appendValue(res []interface{}, val interface{}) []interface{} {
+	if f.unique && containsValue(res, val) {
+		return res
+	}
+	return append(res, val)
+}
+
+func countValues(val interface{}) int {
+	if list, ok := val.([]interface{}); ok {
+		return len(list)
+	}
+	return 1
+}
+
+func containsString(list []string, s string) bool {
+	for _, next := range list {
+		if next == s {
+			return true
+		}
+	}
+	return false
+}
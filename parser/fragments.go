@@ -0,0 +1,351 @@
+// patterns
+package parser
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// name of shared fragments file (with "xml" or "yaml" extension) in patterns directory;
+// fragments are available to patterns of the directory and its subdirectories
+const fragmentsFile = "_fragments"
+
+// Fragments file declaration:
+//
+//	<Fragments>
+//		<Field name="Description" title="Description" type="[]string">...</Field>
+//		<Data name="Trim"><Remove>...</Remove></Data>
+//		<XData name="NoScripts"><Remove>//script</Remove></XData>
+//	</Fragments>
+//
+// patterns reference fragments with "use" attribute; everything declared by referencing element overrides fragment:
+//
+//	<Field title="Description" use="Description"><Path>//div[@class='job']//text()</Path></Field>
+//	<Data use="Trim"><Exclude>^$</Exclude></Data>
+type Fragments struct {
+	Field []*Field
+	Data  []*RegexRules
+	XData []*XpathRules
+}
+
+// fragments available in patterns directory
+type fragmentSet struct {
+	field map[string]*Field
+	data  map[string]*RegexRules
+	xdata map[string]*XpathRules
+}
+
+func newFragmentSet() *fragmentSet {
+	return &fragmentSet{
+		field: make(map[string]*Field),
+		data:  make(map[string]*RegexRules),
+		xdata: make(map[string]*XpathRules),
+	}
+}
+
+// returns copy of set extended with fragments file (if there is one) of directory
+func (s *fragmentSet) loadDir(path string) (*fragmentSet, error) {
+	res := newFragmentSet()
+	if s != nil {
+		for k, v := range s.field {
+			res.field[k] = v
+		}
+		for k, v := range s.data {
+			res.data[k] = v
+		}
+		for k, v := range s.xdata {
+			res.xdata[k] = v
+		}
+	}
+
+	for _, ext := range []string{"xml", "yaml"} {
+		file := path + "/" + fragmentsFile + "." + ext
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		fragments := &Fragments{}
+		if ext == "xml" {
+			err = xml.Unmarshal(data, fragments)
+		} else {
+			err = yaml.Unmarshal(data, fragments)
+		}
+		if err != nil {
+			return nil, errors.New("Fragments " + file + " error: " + err.Error())
+		}
+
+		if err := res.add(fragments); err != nil {
+			return nil, errors.New("Fragments " + file + " error: " + err.Error())
+		}
+	}
+	return res, nil
+}
+
+// fragments of patterns directories, loaded once per directory
+type fragmentDirs struct {
+	root string
+	sets map[string]*fragmentSet
+}
+
+func newFragmentDirs(root string) *fragmentDirs {
+	return &fragmentDirs{root: filepath.Clean(root), sets: make(map[string]*fragmentSet)}
+}
+
+// fragments available to patterns of directory: its own and of parent directories up to the root;
+// directories outside of the root only have their own
+func (d *fragmentDirs) get(dir string) (*fragmentSet, error) {
+	if d == nil {
+		return nil, nil
+	}
+	dir = filepath.Clean(dir)
+	if s, ok := d.sets[dir]; ok {
+		return s, nil
+	}
+
+	var parent *fragmentSet
+	rel, err := filepath.Rel(d.root, dir)
+	if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		if parent, err = d.get(filepath.Dir(dir)); err != nil {
+			return nil, err
+		}
+	}
+	s, err := parent.loadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	d.sets[dir] = s
+	return s, nil
+}
+
+// fragments of the same file must have distinct names
+func (s *fragmentSet) add(fragments *Fragments) error {
+	declared := make(map[string]bool)
+	for _, f := range fragments.Field {
+		name := f.Name
+		if name == "" {
+			name = f.Title
+		}
+		if name == "" || declared["Field "+name] {
+			return errors.New("Field fragment name \"" + name + "\" is missing or not unique")
+		}
+		declared["Field "+name] = true
+		s.field[name] = f
+	}
+	for _, d := range fragments.Data {
+		if d.Name == "" || declared["Data "+d.Name] {
+			return errors.New("Data fragment name \"" + d.Name + "\" is missing or not unique")
+		}
+		declared["Data "+d.Name] = true
+		s.data[d.Name] = d
+	}
+	for _, d := range fragments.XData {
+		if d.Name == "" || declared["XData "+d.Name] {
+			return errors.New("XData fragment name \"" + d.Name + "\" is missing or not unique")
+		}
+		declared["XData "+d.Name] = true
+		s.xdata[d.Name] = d
+	}
+	return nil
+}
+
+// Replaces fragment references of pattern with fragments contents; pattern itself isn't changed
+func (s *fragmentSet) resolve(m *Map) (*Map, error) {
+	res := *m
+	var err error
+	res.URL, err = s.resolveData(m.URL, nil)
+	if err != nil {
+		return nil, errors.New("URL: " + err.Error())
+	}
+	if m.Field != nil {
+		res.Field, err = s.resolveField(m.Field, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &res, nil
+}
+
+// "stack" is the chain of fragments being resolved, to detect cycles
+func (s *fragmentSet) resolveField(f *Field, stack []string) (*Field, error) {
+	res := *f
+	if f.Use != "" {
+		ref := "Field " + f.Use
+		fragment, err := s.lookup(ref, stack)
+		if err != nil {
+			return nil, errors.New("Field " + f.Title + ": " + err.Error())
+		}
+		stack = append(stack, ref)
+
+		base, err := s.resolveField(fragment.(*Field), stack)
+		if err != nil {
+			return nil, err
+		}
+		res = *mergeFields(base, f)
+	}
+	res.Use = ""
+	res.Name = ""
+
+	var err error
+	res.Data, err = s.resolveData(res.Data, stack)
+	if err != nil {
+		return nil, errors.New("Field " + res.Title + ": " + err.Error())
+	}
+	res.XData, err = s.resolveXData(res.XData, stack)
+	if err != nil {
+		return nil, errors.New("Field " + res.Title + ": " + err.Error())
+	}
+
+	res.Field, err = s.resolveFields(res.Field, stack)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Switch != nil {
+		sw := *res.Switch
+		sw.When = make([]*When, len(res.Switch.When))
+		for i, w := range res.Switch.When {
+			when := *w
+			when.Data, err = s.resolveData(w.Data, stack)
+			if err == nil {
+				when.XData, err = s.resolveXData(w.XData, stack)
+			}
+			if err != nil {
+				return nil, errors.New("Field " + res.Title + " When " + w.Name + ": " + err.Error())
+			}
+			when.Field, err = s.resolveFields(w.Field, stack)
+			if err != nil {
+				return nil, err
+			}
+			sw.When[i] = &when
+		}
+		res.Switch = &sw
+	}
+	return &res, nil
+}
+
+func (s *fragmentSet) resolveFields(fields []*Field, stack []string) ([]*Field, error) {
+	res := make([]*Field, len(fields))
+	for i, child := range fields {
+		var err error
+		res[i], err = s.resolveField(child, stack)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *fragmentSet) resolveData(d *RegexRules, stack []string) (*RegexRules, error) {
+	if d == nil || d.Use == "" {
+		return d, nil
+	}
+	ref := "Data " + d.Use
+	fragment, err := s.lookup(ref, stack)
+	if err != nil {
+		return nil, err
+	}
+	base, err := s.resolveData(fragment.(*RegexRules), append(stack, ref))
+	if err != nil {
+		return nil, err
+	}
+	res := *base
+	override(&res, d)
+	res.Use, res.Name = "", ""
+	return &res, nil
+}
+
+func (s *fragmentSet) resolveXData(d *XpathRules, stack []string) (*XpathRules, error) {
+	if d == nil || d.Use == "" {
+		return d, nil
+	}
+	ref := "XData " + d.Use
+	fragment, err := s.lookup(ref, stack)
+	if err != nil {
+		return nil, err
+	}
+	base, err := s.resolveXData(fragment.(*XpathRules), append(stack, ref))
+	if err != nil {
+		return nil, err
+	}
+	res := *base
+	override(&res, d)
+	res.Use, res.Name = "", ""
+	return &res, nil
+}
+
+// finds fragment by reference like "Field Description"; fails if it's already being resolved
+func (s *fragmentSet) lookup(ref string, stack []string) (interface{}, error) {
+	for i, next := range stack {
+		if next == ref {
+			return nil, errors.New("fragments cycle: " + strings.Join(append(stack[i:], ref), " -> "))
+		}
+	}
+
+	parts := strings.SplitN(ref, " ", 2)
+	var fragment interface{}
+	var ok bool
+	if s != nil {
+		switch parts[0] {
+		case "Field":
+			fragment, ok = s.field[parts[1]]
+		case "Data":
+			fragment, ok = s.data[parts[1]]
+		case "XData":
+			fragment, ok = s.xdata[parts[1]]
+		}
+	}
+	if !ok {
+		return nil, errors.New("unknown " + parts[0] + " fragment \"" + parts[1] + "\"")
+	}
+	return fragment, nil
+}
+
+// field declared by fragment, overridden by referencing field;
+// sub-fields are merged by title
+func mergeFields(base, f *Field) *Field {
+	res := *base
+	children := base.Field
+	override(&res, f)
+
+	res.Field = append([]*Field{}, children...)
+	for _, child := range f.Field {
+		replaced := false
+		for i, prev := range res.Field {
+			if prev.Title == child.Title {
+				res.Field[i] = child
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			res.Field = append(res.Field, child)
+		}
+	}
+	return &res
+}
+
+// sets every non-zero field of "src" struct to "dst" struct of the same type
+func override(dst, src interface{}) {
+	d := reflect.ValueOf(dst).Elem()
+	s := reflect.ValueOf(src).Elem()
+	for i := 0; i < s.NumField(); i++ {
+		if !isZero(s.Field(i)) {
+			d.Field(i).Set(s.Field(i))
+		}
+	}
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
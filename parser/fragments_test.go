@@ -0,0 +1,118 @@
+// patterns
+package parser
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var fragmentsStr = `
+<Fragments>
+	<Data name="Trim">
+		<Remove><![CDATA[
+			^[\x20\x09\x0D\x0A]+
+			[\x20\x09\x0D\x0A]+$
+		]]></Remove>
+	</Data>
+	<Data name="Text" use="Trim">
+		<Exclude><![CDATA[
+			^[\x20\x09\x0D\x0A]*$
+		]]></Exclude>
+	</Data>
+	<Field title="Description" type="[]string">
+		<Path>//div[@class='description']/descendant::*/text()</Path>
+		<Data use="Text"/>
+	</Field>
+	<Field name="Job" title="Job" type="struct">
+		<Path>//body</Path>
+		<Field title="Title" type="string">
+			<Path>//h1</Path>
+			<Data use="Trim"/>
+		</Field>
+		<Field title="Description" use="Description"/>
+	</Field>
+</Fragments>
+`
+
+var fragmentsHtml = `<html><body>
+	<h1>  Go developer </h1>
+	<div class="description"><p> Write Go </p><p>  </p><p>Remote </p></div>
+	<div class="about"><p> Acme </p></div>
+</body></html>`
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "fragments")
+	assert.NoError(t, err)
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestLoadFragments(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"_fragments.xml": fragmentsStr,
+		"site/Body.xml": `<Pattern mime="html">
+			<Field title="Body" use="Job">
+				<Field title="About" use="Description">
+					<Path>//div[@class='about']/descendant::*/text()</Path>
+				</Field>
+			</Field>
+		</Pattern>`,
+		"site/Unknown.xml": `<Pattern mime="html">
+			<Field title="Body" type="struct">
+				<Path>//body</Path>
+				<Field title="Title" type="string"><Path>//h1</Path><Data use="Strip"/></Field>
+			</Field>
+		</Pattern>`,
+		"cycle/_fragments.xml": `<Fragments>
+			<Field title="A" type="struct"><Path>//a</Path><Field title="B" use="B"/></Field>
+			<Field title="B" use="A"/>
+		</Fragments>`,
+		"cycle/Body.xml": `<Pattern mime="html"><Field title="Body" use="A"/></Pattern>`,
+	})
+	defer os.RemoveAll(dir)
+
+	var logs bytes.Buffer
+	p := NewPatterns(log.New(&logs, "", 0))
+	assert.NoError(t, p.LoadTree(dir))
+
+	data, err := p.Apply("", strings.NewReader(fragmentsHtml))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"site": map[string]interface{}{
+			"Body.xml": map[string]interface{}{
+				"Body": map[string]interface{}{
+					"Title":       "Go developer",
+					"Description": []interface{}{"Write Go", "Remote"},
+					"About":       []interface{}{"Acme"},
+				},
+			},
+		},
+	}, data)
+
+	assert.Contains(t, logs.String(), dir+`/site/Unknown.xml. Field Title: unknown Data fragment "Strip"`)
+	assert.Contains(t, logs.String(), dir+`/cycle/Body.xml. Field B: fragments cycle: Field A -> Field B -> Field A`)
+}
+
+func TestLoadFragments_errors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"_fragments.xml": `<Fragments><Data name="Trim"/><Data name="Trim"/></Fragments>`,
+	})
+	defer os.RemoveAll(dir)
+
+	assert.Error(t, NewPatterns(nil).LoadTree(dir))
+
+	// no fragments outside of patterns tree
+	p := NewPatterns(nil)
+	assert.Error(t, p.LoadXml(p.Tree, []byte(`<Pattern mime="html"><Field title="Body" use="Job"/></Pattern>`), "Body.xml"))
+}
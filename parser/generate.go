@@ -0,0 +1,390 @@
+// patterns
+package parser
+
+import (
+	"go/format"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Go types and JSON Schema documents describing output of patterns, so consumers don't have to
+// maintain them by hand. Values which can be null are pointers in Go types (slices are nil) and
+// allow "null" in schemas; keys left out of output (omitempty fields, fields of Switch branches)
+// have "omitempty" JSON option and aren't required by schemas
+
+// Generates Go source of package "pkg" with types matching output of ApplyPatterns for the tree:
+// "Result" type for the whole tree, a type per directory, pattern and struct field
+func (pn *PatternNode) GoTypes(pkg string) ([]byte, error) {
+	g := &goGenerator{names: make(map[string]bool)}
+	g.node(pn, "Result", nil)
+
+	src := "// Code generated from descry patterns. DO NOT EDIT.\n\npackage " + pkg + "\n\n" + strings.Join(g.types, "\n")
+	return format.Source([]byte(src))
+}
+
+// JSON Schema documents of every pattern of the tree, keyed by pattern path like "startupgigs/stackoverflow.com/Body.xml"
+func (pn *PatternNode) JsonSchemas() map[string]map[string]interface{} {
+	res := make(map[string]map[string]interface{})
+	pn.walk("", func(path string, m *CompiledMap) {
+		schema := m.JsonSchema()
+		schema["title"] = path
+		res[path] = schema
+	})
+	return res
+}
+
+// calls fn for every pattern of the tree in order of their paths
+func (pn *PatternNode) walk(prefix string, fn func(path string, m *CompiledMap)) {
+	for _, key := range pn.keys() {
+		switch val := (*pn)[key].(type) {
+		case *CompiledMap:
+			fn(prefix+key, val)
+		case *PatternNode:
+			val.walk(prefix+key+"/", fn)
+		}
+	}
+}
+
+func (pn *PatternNode) keys() []string {
+	res := make([]string, 0, len(*pn))
+	for key := range *pn {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// JSON Schema of pattern output
+func (p *CompiledMap) JsonSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"type":                 "object",
+		"properties":           map[string]interface{}{p.field.title: p.field.jsonSchema(false)},
+		"required":             []string{p.field.title},
+		"additionalProperties": false,
+	}
+}
+
+// true if output could have null value for the field
+func (f *CompiledField) nullable() bool {
+	if f.def != "" && f.dataType.depth() == 0 && !f.multiple {
+		return false
+	}
+	return f.optional || f.required == RequiredWarn ||
+		f.constraints != nil && f.constraints.onInvalid == InvalidNull
+}
+
+// number of list levels of field value
+func (f *CompiledField) listDepth() int {
+	depth := f.dataType.depth()
+	if depth == 0 && f.multiple {
+		depth = 1
+	}
+	return depth
+}
+
+// null is only allowed if "nullable" is set
+func (f *CompiledField) jsonSchema(nullable bool) map[string]interface{} {
+	schema := f.elemSchema()
+	for i := 0; i < f.listDepth(); i++ {
+		schema = map[string]interface{}{"type": "array", "items": schema}
+	}
+	if nullable {
+		if t, ok := schema["type"].(string); ok {
+			schema["type"] = []string{t, "null"}
+		} else {
+			schema = map[string]interface{}{"anyOf": []interface{}{schema, map[string]interface{}{"type": "null"}}}
+		}
+	}
+	return schema
+}
+
+// schema of single (not list) value
+func (f *CompiledField) elemSchema() map[string]interface{} {
+	base := f.dataType.base()
+	schema := make(map[string]interface{})
+	switch {
+	case base.kind == reflect.Struct:
+		return f.structSchema()
+	case base.kind == reflect.Map:
+		schema["type"] = "object"
+	case base.isDate:
+		schema["type"], schema["format"] = "string", "date"
+	case base.isTime:
+		schema["type"], schema["format"] = "string", "date-time"
+	case base.isUrl:
+		schema["type"], schema["format"] = "string", "uri"
+	case base.isHtml:
+		schema["type"], schema["contentMediaType"] = "string", "text/html"
+	case base.kind == reflect.Int, base.kind == reflect.Int64:
+		schema["type"] = "integer"
+	case base.kind == reflect.Uint:
+		schema["type"], schema["minimum"] = "integer", 0
+	case base.kind == reflect.Float64:
+		schema["type"] = "number"
+	case base.kind == reflect.Bool:
+		schema["type"] = "boolean"
+	default:
+		schema["type"] = "string"
+	}
+
+	if c := f.constraints; c != nil {
+		if c.min != nil {
+			schema["minimum"] = *c.min
+		}
+		if c.max != nil {
+			schema["maximum"] = *c.max
+		}
+		if c.minLength > 0 {
+			schema["minLength"] = c.minLength
+		}
+		if c.maxLength > 0 {
+			schema["maxLength"] = c.maxLength
+		}
+		if c.enum != nil {
+			enum := make([]interface{}, 0, len(c.enum))
+			for _, v := range c.enum {
+				if val, err := base.Convert([]byte(v)); err == nil {
+					enum = append(enum, val)
+				}
+			}
+			schema["enum"] = enum
+		}
+		if c.match != nil {
+			schema["pattern"] = c.match.String()
+		}
+		switch c.format {
+		case "email", "uuid":
+			schema["format"] = c.format
+		case "url":
+			schema["format"] = "uri"
+		}
+	}
+	return schema
+}
+
+// object schema; structs with Switch are one of branch objects
+func (f *CompiledField) structSchema() map[string]interface{} {
+	if f.variants == nil {
+		return f.objectSchema(f.field, nil)
+	}
+	branches := make([]interface{}, 0, len(f.variants))
+	for _, v := range f.variants {
+		branches = append(branches, f.objectSchema(f.fields(v), v))
+	}
+	return map[string]interface{}{"oneOf": branches}
+}
+
+func (f *CompiledField) objectSchema(fields []*CompiledField, v *compiledVariant) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+	for _, child := range fields {
+		// omitempty fields are left out rather than null
+		properties[child.title] = child.jsonSchema(child.nullable() && !child.omitEmpty)
+		if !child.omitEmpty {
+			required = append(required, child.title)
+		}
+	}
+	if v != nil {
+		properties[f.variantTitle] = map[string]interface{}{"const": v.name}
+		required = append(required, f.variantTitle)
+	}
+	if f.grouped {
+		// groups without sub-fields are strings
+		for _, name := range f.data.Groups() {
+			if _, ok := properties[name]; !ok {
+				properties[name] = map[string]interface{}{"type": []string{"string", "null"}}
+				required = append(required, name)
+			}
+		}
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+type goGenerator struct {
+	// type declarations in order of generation
+	types []string
+	names map[string]bool
+}
+
+// reserves unique type name
+func (g *goGenerator) typeName(name string) string {
+	res := name
+	for i := 2; g.names[res]; i++ {
+		res = name + strconv.Itoa(i)
+	}
+	g.names[res] = true
+	return res
+}
+
+type goField struct {
+	name, goType, key string
+	omitempty         bool
+}
+
+// reserves place of type declaration, so types are declared before types of their fields
+func (g *goGenerator) reserve() int {
+	g.types = append(g.types, "")
+	return len(g.types) - 1
+}
+
+func (g *goGenerator) declare(index int, name string, fields []goField) {
+	var b strings.Builder
+	b.WriteString("type " + name + " struct {\n")
+	used := make(map[string]bool)
+	for _, field := range fields {
+		fieldName := field.name
+		for i := 2; used[fieldName]; i++ {
+			fieldName = field.name + strconv.Itoa(i)
+		}
+		used[fieldName] = true
+
+		tag := field.key
+		if field.omitempty {
+			tag += ",omitempty"
+		}
+		b.WriteString("\t" + fieldName + " " + field.goType + " `json:" + strconv.Quote(tag) + " descry:" + strconv.Quote(field.key) + "`\n")
+	}
+	b.WriteString("}\n")
+	g.types[index] = b.String()
+}
+
+// declares type of directory: patterns and sub-directories are missing unless they retrieved something
+func (g *goGenerator) node(pn *PatternNode, name string, path []string) string {
+	name = g.typeName(name)
+	index := g.reserve()
+
+	fields := make([]goField, 0)
+	for _, key := range pn.keys() {
+		childPath := append(append([]string{}, path...), key)
+		var goType string
+		switch val := (*pn)[key].(type) {
+		case *CompiledMap:
+			goType = g.pattern(val, goName(strings.Join(childPath, " ")))
+		case *PatternNode:
+			goType = g.node(val, goName(strings.Join(childPath, " ")), childPath)
+		default:
+			continue
+		}
+		fields = append(fields, goField{goName(key), "*" + goType, key, true})
+	}
+
+	g.declare(index, name, fields)
+	return name
+}
+
+func (g *goGenerator) pattern(m *CompiledMap, name string) string {
+	name = g.typeName(name)
+	index := g.reserve()
+
+	field := goField{goName(m.field.title), g.fieldType(m.field, name+goName(m.field.title), false), m.field.title, false}
+	g.declare(index, name, []goField{field})
+	return name
+}
+
+// Go type of field value; "name" is used for struct types, values which can be null or left out are pointers
+func (g *goGenerator) fieldType(f *CompiledField, name string, nullable bool) string {
+	base := f.dataType.base()
+	var elem string
+	switch {
+	case base.kind == reflect.Struct:
+		elem = g.structType(f, name)
+	case base.kind == reflect.Map:
+		elem = "map[string]interface{}"
+	case base.kind == reflect.Int:
+		elem = "int"
+	case base.kind == reflect.Int64:
+		elem = "int64"
+	case base.kind == reflect.Uint:
+		elem = "uint"
+	case base.kind == reflect.Float64:
+		elem = "float64"
+	case base.kind == reflect.Bool:
+		elem = "bool"
+	default:
+		// "time" and "date" are RFC 3339 strings
+		elem = "string"
+	}
+
+	depth := f.listDepth()
+	if depth > 0 {
+		return strings.Repeat("[]", depth) + elem
+	}
+	if nullable {
+		return "*" + elem
+	}
+	return elem
+}
+
+func (g *goGenerator) structType(f *CompiledField, name string) string {
+	name = g.typeName(name)
+	index := g.reserve()
+
+	fields := make([]goField, 0)
+	titles := make([]string, 0)
+	add := func(child *CompiledField, omitted bool) {
+		if containsString(titles, child.title) {
+			return
+		}
+		titles = append(titles, child.title)
+		childType := g.fieldType(child, name+goName(child.title), omitted || child.omitEmpty || child.nullable())
+		fields = append(fields, goField{goName(child.title), childType, child.title, omitted || child.omitEmpty})
+	}
+
+	for _, child := range f.field {
+		add(child, false)
+	}
+	if f.variants != nil {
+		fields = append(fields, goField{goName(f.variantTitle), "string", f.variantTitle, false})
+		titles = append(titles, f.variantTitle)
+		// branch fields are only there for items of the branch
+		for _, v := range f.variants {
+			for _, child := range v.field {
+				add(child, true)
+			}
+		}
+	}
+	if f.grouped {
+		for _, group := range f.data.Groups() {
+			if !containsString(titles, group) {
+				titles = append(titles, group)
+				fields = append(fields, goField{goName(group), "*string", group, false})
+			}
+		}
+	}
+
+	g.declare(index, name, fields)
+	return name
+}
+
+// exported Go identifier made of letters and digits of s, like "stackoverflow.com" to "StackoverflowCom"
+func goName(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(r) {
+			b.WriteString("X")
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "X"
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+// patterns
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var generatePatternStr = `
+<Pattern mime="html">
+	<Field title="Job" type="[]struct">
+		<Path>//li</Path>
+		<Field title="Title" type="string" minlength="3">
+			<Path>.//h2</Path>
+		</Field>
+		<Field title="Salary" type="int" optional="true" min="0">
+			<Path>.//span[@class='salary']</Path>
+		</Field>
+		<Field title="Tags" type="string" multiple="true" omitempty="true">
+			<Path>.//span[@class='tag']</Path>
+		</Field>
+		<Field title="Posted" type="date" default="2020-01-01">
+			<Path>.//time</Path>
+		</Field>
+		<Switch title="Kind">
+			<When name="remote">
+				<XData>.//span[@class='remote']</XData>
+				<Field title="Timezone" type="string">
+					<Path>.//span[@class='tz']</Path>
+				</Field>
+			</When>
+			<When name="office">
+				<Field title="City" type="string" enum="Kyiv,Lviv">
+					<Path>.//span[@class='city']</Path>
+				</Field>
+			</When>
+		</Switch>
+	</Field>
+</Pattern>
+`
+
+func TestGenerate(t *testing.T) {
+	p := NewPatterns(nil)
+	node := &PatternNode{}
+	assert.NoError(t, p.LoadXml(node, []byte(generatePatternStr), "Body.xml"))
+	p.Tree = &PatternNode{"jobs.example.com": node}
+
+	src, err := p.Tree.GoTypes("jobs")
+	assert.NoError(t, err)
+	for _, decl := range []string{
+		"package jobs",
+		"type Result struct {\n\tJobsExampleCom *JobsExampleCom `json:\"jobs.example.com,omitempty\" descry:\"jobs.example.com\"`\n}",
+		"BodyXml *JobsExampleComBodyXml `json:\"Body.xml,omitempty\" descry:\"Body.xml\"`",
+		"Job []JobsExampleComBodyXmlJob `json:\"Job\" descry:\"Job\"`",
+		"Title    string   `json:\"Title\" descry:\"Title\"`",
+		"Salary   *int     `json:\"Salary\" descry:\"Salary\"`",
+		"Tags     []string `json:\"Tags,omitempty\" descry:\"Tags\"`",
+		"Posted   string   `json:\"Posted\" descry:\"Posted\"`",
+		"Kind     string   `json:\"Kind\" descry:\"Kind\"`",
+		"City     *string  `json:\"City,omitempty\" descry:\"City\"`",
+	} {
+		assert.Contains(t, string(src), decl)
+	}
+
+	schemas := p.Tree.JsonSchemas()
+	schema := schemas["jobs.example.com/Body.xml"]
+	assert.Equal(t, "jobs.example.com/Body.xml", schema["title"])
+	assert.Equal(t, []string{"Job"}, schema["required"])
+
+	job := schema["properties"].(map[string]interface{})["Job"].(map[string]interface{})
+	assert.Equal(t, "array", job["type"])
+	branches := job["items"].(map[string]interface{})["oneOf"].([]interface{})
+	assert.Len(t, branches, 2)
+
+	office := branches[1].(map[string]interface{})
+	properties := office["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"const": "office"}, properties["Kind"])
+	assert.Equal(t, map[string]interface{}{"type": []string{"integer", "null"}, "minimum": 0.0}, properties["Salary"])
+	assert.Equal(t, map[string]interface{}{"type": "string", "format": "date"}, properties["Posted"])
+	assert.Equal(t, map[string]interface{}{"type": "string", "enum": []interface{}{"Kyiv", "Lviv"}}, properties["City"])
+	assert.NotContains(t, properties, "Timezone")
+	// omitempty fields aren't required
+	assert.Equal(t, []string{"Title", "Salary", "Posted", "City", "Kind"}, office["required"])
+
+	// generated types decode retrieved data
+	data, err := p.Apply("", strings.NewReader(`<html><body><ul>
+		<li><h2>Go developer</h2><span class="salary">100</span><span class="remote"></span><span class="tz">UTC</span></li>
+	</ul></body></html>`))
+	assert.NoError(t, err)
+	var res struct {
+		Job []struct {
+			Title    string  `descry:"Title"`
+			Salary   *int    `descry:"Salary"`
+			Posted   string  `descry:"Posted"`
+			Kind     string  `descry:"Kind"`
+			Timezone *string `descry:"Timezone"`
+		} `descry:"jobs.example.com/Body.xml/Job"`
+	}
+	assert.NoError(t, Decode(data, &res))
+	assert.Equal(t, "remote", res.Job[0].Kind)
+	assert.Equal(t, "UTC", *res.Job[0].Timezone)
+
+	assert.Equal(t, "StackoverflowCom", goName("stackoverflow.com"))
+	assert.Equal(t, "X4chanOrg", goName("4chan.org"))
+}
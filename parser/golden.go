@@ -0,0 +1,235 @@
+// patterns
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Golden files are regression tests of patterns: documents pattern applies to are stored next to it
+// with expected output, like:
+//
+//	startupgigs/stackoverflow.com/Item.xml
+//	startupgigs/stackoverflow.com/_golden/Item.xml/search.html           document ("html", "json" or "xml")
+//	startupgigs/stackoverflow.com/_golden/Item.xml/search.html.golden    expected output
+//
+// golden file is JSON with document URL, response date (anchor for relative dates, current time if empty)
+// and pattern output (null if pattern doesn't apply):
+//
+//	{"URL": "https://stackoverflow.com/jobs?q=go", "Date": "2018-05-01T00:00:00Z", "Output": {"Item": [...]}}
+//
+// new test case only needs golden file with URL, its Date and Output are filled by update mode
+const (
+	goldenDir = "_golden"
+	goldenExt = ".golden"
+)
+
+type golden struct {
+	URL    string
+	Date   string `json:",omitempty"`
+	Output interface{}
+}
+
+// Result of golden file test case
+type GoldenResult struct {
+	// pattern path, like: startupgigs/stackoverflow.com/Item.xml
+	Pattern string
+
+	// document file
+	File string
+
+	// differences of output and golden file, like: Item[2].Title: "Go developer" expected, got "Designer"
+	Diffs []string
+
+	// golden file was rewritten with current output
+	Updated bool
+
+	// document or golden file couldn't be read
+	Err error
+}
+
+func (r *GoldenResult) Failed() bool {
+	return r.Err != nil || len(r.Diffs) > 0
+}
+
+// Applies patterns to documents stored in "_golden" directories of patterns directory "dir" the tree was loaded from
+// and compares outputs with golden files; only patterns whose paths match "filter" are tested unless it's nil.
+// If "update" is set, golden files of failed cases are rewritten
+func (p *Patterns) RunGolden(dir string, filter *regexp.Regexp, update bool) []*GoldenResult {
+	res := make([]*GoldenResult, 0)
+	p.Tree.walk("", func(path string, m *CompiledMap) {
+		if filter != nil && !filter.MatchString(path) {
+			return
+		}
+		pattern := filepath.Join(dir, filepath.FromSlash(path))
+		cases := filepath.Join(filepath.Dir(pattern), goldenDir, filepath.Base(pattern))
+		files, err := ioutil.ReadDir(cases)
+		if err != nil {
+			// pattern has no test cases
+			return
+		}
+		for _, f := range files {
+			if f.IsDir() || strings.HasSuffix(f.Name(), goldenExt) {
+				continue
+			}
+			res = append(res, p.runGolden(path, filepath.Join(cases, f.Name()), update))
+		}
+	})
+	return res
+}
+
+func (p *Patterns) runGolden(path, file string, update bool) *GoldenResult {
+	res := &GoldenResult{Pattern: path, File: file}
+
+	ctx := &Context{Mime: strings.TrimPrefix(filepath.Ext(file), ".")}
+	switch ctx.Mime {
+	case "html", "json", "xml":
+	case "htm":
+		ctx.Mime = "html"
+	default:
+		res.Err = errors.New("Unsupported document " + file + ", extension must be html, json or xml")
+		return res
+	}
+
+	data, err := ioutil.ReadFile(file + goldenExt)
+	if err != nil {
+		res.Err = errors.New("Missing golden file " + file + goldenExt + ", create it with document URL, like {\"URL\": \"https://...\"}, and run update")
+		return res
+	}
+	expected := &golden{}
+	if err = json.Unmarshal(data, expected); err != nil {
+		res.Err = errors.New("Golden file " + file + goldenExt + " error: " + err.Error())
+		return res
+	}
+	ctx.URL = expected.URL
+	if expected.Date != "" {
+		if ctx.Date, err = time.Parse(time.RFC3339, expected.Date); err != nil {
+			res.Err = errors.New("Golden file " + file + goldenExt + " error: " + err.Error())
+			return res
+		}
+	} else if update {
+		// relative dates of updated output are anchored to the date stored
+		ctx.Date = time.Now().UTC().Truncate(time.Second)
+	}
+
+	content, err := os.Open(file)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer content.Close()
+	output, err := p.ApplyContext(ctx, content)
+	if err != nil {
+		res.Err = errors.New("Document " + file + " error: " + err.Error())
+		return res
+	}
+
+	// compared the way they are stored: numbers are float64, times are strings etc.
+	var actual interface{}
+	if found, ok := lookupPath(output, path); ok {
+		data, err := json.Marshal(found)
+		if err != nil {
+			res.Err = err
+			return res
+		}
+		json.Unmarshal(data, &actual)
+	}
+	res.Diffs = diffValues("", expected.Output, actual)
+	if len(res.Diffs) == 0 || !update {
+		return res
+	}
+
+	expected.Output = actual
+	if expected.Date == "" {
+		expected.Date = ctx.Date.Format(time.RFC3339)
+	}
+	data, err = json.MarshalIndent(expected, "", "\t")
+	if err == nil {
+		err = ioutil.WriteFile(file+goldenExt, append(data, '\n'), 0644)
+	}
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Updated = true
+	return res
+}
+
+// differences of decoded JSON values, keyed by their paths like: Item[2].Title
+func diffValues(path string, expected, actual interface{}) []string {
+	res := make([]string, 0)
+	describe := func(format string) string {
+		if path == "" {
+			return format
+		}
+		return path + ": " + format
+	}
+
+	switch expected := expected.(type) {
+	case map[string]interface{}:
+		actual, ok := actual.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0)
+		for key := range expected {
+			keys = append(keys, key)
+		}
+		for key := range actual {
+			if _, ok := expected[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			expectedVal, expectedOk := expected[key]
+			actualVal, actualOk := actual[key]
+			switch {
+			case !actualOk:
+				res = append(res, describe("key "+strconv.Quote(key)+" is missing"))
+			case !expectedOk:
+				res = append(res, describe("unexpected key "+strconv.Quote(key)+" = "+diffText(actualVal)))
+			default:
+				res = append(res, diffValues(joinPath(path, key), expectedVal, actualVal)...)
+			}
+		}
+		return res
+	case []interface{}:
+		actual, ok := actual.([]interface{})
+		if !ok {
+			break
+		}
+		if len(expected) != len(actual) {
+			res = append(res, describe(strconv.Itoa(len(expected))+" items expected, got "+strconv.Itoa(len(actual))))
+		}
+		for i := 0; i < len(expected) && i < len(actual); i++ {
+			res = append(res, diffValues(path+"["+strconv.Itoa(i)+"]", expected[i], actual[i])...)
+		}
+		return res
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		res = append(res, describe(diffText(expected)+" expected, got "+diffText(actual)))
+	}
+	return res
+}
+
+func diffText(val interface{}) string {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return exprText(val)
+	}
+	if len(data) > 80 {
+		return truncateText(string(data), 77)
+	}
+	return string(data)
+}
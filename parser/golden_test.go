@@ -0,0 +1,69 @@
+// patterns
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunGolden(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"site/Item.xml": `<Pattern mime="html">
+	<URL><Include>^https://example\.com/jobs</Include></URL>
+	<Field title="Item" type="[]struct">
+		<Path>//li</Path>
+		<Field title="Title" type="string"><Path>.//h2</Path></Field>
+		<Field title="Salary" type="int" optional="true"><Path>.//span</Path></Field>
+	</Field>
+</Pattern>`,
+		"site/_golden/Item.xml/list.html": `<html><body><ul>
+	<li><h2>Go developer</h2><span>100</span></li>
+	<li><h2>Designer</h2></li>
+</ul></body></html>`,
+		"site/_golden/Item.xml/list.html.golden":      `{"URL": "https://example.com/jobs", "Date": "2018-05-01T00:00:00Z"}`,
+		"site/_golden/Item.xml/notes.txt":             ``,
+		"site/_golden/Item.xml/unrelated.html":        `<html></html>`,
+		"site/_golden/Item.xml/unrelated.html.golden": `{"URL": "https://example.com/about", "Output": null}`,
+	})
+	defer os.RemoveAll(dir)
+
+	p := NewPatterns(nil)
+	assert.NoError(t, p.LoadTree(dir))
+
+	// cases are ordered by file
+	results := p.RunGolden(dir, nil, false)
+	assert.Len(t, results, 3)
+	assert.Equal(t, "site/Item.xml", results[0].Pattern)
+	assert.Equal(t, []string{`null expected, got {"Item":[{"Salary":100,"Title":"Go developer"},{"Salary":null,"Title":"Design...`}, results[0].Diffs)
+	assert.Error(t, results[1].Err)
+	assert.False(t, results[2].Failed())
+
+	// update fills output in
+	results = p.RunGolden(dir, regexp.MustCompile(`^site/`), true)
+	assert.True(t, results[0].Updated)
+	assert.False(t, results[2].Updated)
+	data, err := ioutil.ReadFile(filepath.Join(dir, "site/_golden/Item.xml/list.html.golden"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"Title": "Designer"`)
+	assert.Contains(t, string(data), `"Date": "2018-05-01T00:00:00Z"`)
+	assert.False(t, p.RunGolden(dir, nil, false)[0].Failed())
+
+	// changed extraction is reported by paths of values
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "site/_golden/Item.xml/list.html"), []byte(`<html><body><ul>
+	<li><h2>Go developer</h2><span>120</span></li>
+</ul></body></html>`), 0644))
+	assert.Equal(t, []string{
+		"Item: 2 items expected, got 1",
+		"Item[0].Salary: 100 expected, got 120",
+	}, p.RunGolden(dir, nil, false)[0].Diffs)
+
+	assert.Empty(t, p.RunGolden(dir, regexp.MustCompile(`^other/`), false))
+	assert.Equal(t, []string{`unexpected key "Extra" = true`, `Title: key "Salary" is missing`}, diffValues("",
+		map[string]interface{}{"Title": map[string]interface{}{"Salary": 1.0}},
+		map[string]interface{}{"Title": map[string]interface{}{}, "Extra": true}))
+}
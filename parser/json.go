@@ -0,0 +1,139 @@
+// patterns
+package parser
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Retrieves field from parsed JSON document ("root"); relative paths start at "node"
+func (f *CompiledField) retrieveJson(ctx *Context, root, node interface{}, report *Report) (result interface{}) {
+	// element type of slices, type itself otherwise
+	dataType := f.dataType.base()
+
+	// "[][]string" like types group values by node found
+	nested := f.dataType.depth() > 1
+
+	if f.capture != "" {
+		return f.retrieveCapture(ctx, report)
+	}
+
+	if f.expr != nil {
+		return f.retrieveComputed(ctx, nil, report)
+	}
+
+	if dataType.kind != reflect.Struct || f.grouped {
+		// check every Path provided
+		for _, query := range f.jsonPath {
+			found := query.Evaluate(root, node)
+			report.hit(query.String(), len(found))
+
+			if f.multiple {
+				res := make([]interface{}, 0)
+				for _, next := range found {
+					// values found within current node only
+					group := make([]interface{}, 0)
+					for _, item := range jsonItems(next) {
+						bts, ok := jsonToBytes(item)
+						if !ok || !f.testData(bts, report) {
+							continue
+						}
+						for _, val := range f.matchValues(ctx, bts, report) {
+							if nested {
+								group = f.appendValue(group, val)
+							} else {
+								res = f.appendValue(res, val)
+							}
+						}
+					}
+					if nested && len(group) > 0 {
+						res = append(res, group)
+					}
+				}
+				result = interface{}(res)
+			} else if len(found) > 0 {
+				if bts, ok := jsonToBytes(found[0]); ok && f.testData(bts, report) {
+					result = f.matchValue(ctx, bts, report)
+				}
+			}
+
+			// exit if at least 1 value found for Path
+			if result != nil {
+				report.matched(query.String(), countValues(result))
+				return result
+			}
+		}
+	} else {
+		// only one path available works for struct
+		if len(f.jsonPath) == 0 {
+			return nil
+		}
+		query := f.jsonPath[0]
+		found := query.Evaluate(root, node)
+		report.hit(query.String(), len(found))
+		if len(found) == 0 {
+			report.add(DiagNoMatch, "path matched no nodes", nil)
+		}
+
+		if f.multiple {
+			res := make([]interface{}, 0)
+			for _, next := range found {
+				for _, item := range jsonItems(next) {
+					if val := f.retrieveJsonStruct(ctx, root, item, report); val != nil {
+						res = append(res, val)
+					}
+				}
+			}
+			result = res
+			report.matched(query.String(), len(res))
+		} else if len(found) > 0 {
+			if val := f.retrieveJsonStruct(ctx, root, found[0], report); val != nil {
+				result = val
+				report.matched(query.String(), 1)
+			}
+		}
+	}
+
+	return result
+}
+
+func (f *CompiledField) retrieveJsonStruct(ctx *Context, root, node interface{}, report *Report) map[string]interface{} {
+	v, ok := f.variant(nil, func() []byte {
+		bts, _ := jsonToBytes(node)
+		return bts
+	}, report)
+	if !ok {
+		return nil
+	}
+	return f.collectStruct(ctx, report, v, func(ctx *Context, child *CompiledField, childReport *Report) interface{} {
+		return child.retrieveJson(ctx, root, node, childReport)
+	})
+}
+
+// arrays are expanded to their elements
+func jsonItems(node interface{}) []interface{} {
+	if list, ok := node.([]interface{}); ok {
+		return list
+	}
+	return []interface{}{node}
+}
+
+// JSON value as text regex rules and conversion are applied to; false for null
+func jsonToBytes(node interface{}) ([]byte, bool) {
+	switch n := node.(type) {
+	case nil:
+		return nil, false
+	case string:
+		return []byte(n), true
+	case json.Number:
+		return []byte(n.String()), true
+	case bool:
+		if n {
+			return []byte("true"), true
+		}
+		return []byte("false"), true
+	}
+	// objects and arrays are kept as JSON
+	bts, err := json.Marshal(node)
+	return bts, err == nil
+}
@@ -0,0 +1,152 @@
+// patterns
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var jsonDoc = `{
+	"total": "1,204",
+	"jobs": [
+		{"id": 17, "title": "  Go developer ", "tags": ["go", "sql"], "salary": {"min": 80000.5}, "remote": true},
+		{"id": 18, "title": "Sponsored", "tags": [], "salary": null, "remote": false},
+		{"id": 19, "title": "Designer", "tags": ["ux"], "remote": false}
+	]
+}`
+
+func TestJsonPath(t *testing.T) {
+	doc, err := ParseDocument("json", strings.NewReader(jsonDoc))
+	assert.NoError(t, err)
+
+	var cases = []struct {
+		path  string
+		count int
+	}{
+		{"$.jobs", 1},
+		{"$.jobs[*]", 3},
+		{"$.jobs[0].tags[*]", 2},
+		{"$.jobs[-1].title", 1},
+		{"$.jobs[0:2].id", 2},
+		{"$..tags", 3},
+		{"$.jobs[?(@.remote == true)].id", 1},
+		{"$.jobs[?(@.id != 18)].id", 2},
+		{"$.jobs[?(@.salary.min)]", 1},
+		{"$['jobs'][1,2]['title']", 2},
+		{"$.missing", 0},
+	}
+	for _, c := range cases {
+		p, err := CompileJsonPath(c.path)
+		if assert.NoError(t, err, c.path) {
+			assert.Len(t, p.Evaluate(doc.Json, doc.Json), c.count, c.path)
+		}
+	}
+
+	for _, bad := range []string{"$.jobs[", "$.jobs[x]", "$.jobs[?(@.a == {})]", "$.jobs."} {
+		_, err := CompileJsonPath(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+var jsonPatternStr = `
+<Pattern mime="json">
+	<URL>
+		<Include><![CDATA[
+			^https://example.com/api/jobs
+		]]></Include>
+	</URL>
+	<Field title="Jobs" type="struct">
+		<Path>$</Path>
+		<Field title="Total" type="int" lenient="true">
+			<Path>total</Path>
+		</Field>
+		<Field title="Item" type="[]struct">
+			<Path>jobs</Path>
+			<Field title="Id" type="int">
+				<Path>@.id</Path>
+			</Field>
+			<Field title="Title" type="string">
+				<Path>title</Path>
+				<Data>
+					<Exclude>^Sponsored</Exclude>
+					<Remove><![CDATA[
+						^[\x20\x09\x0D\x0A]+
+						[\x20\x09\x0D\x0A]+$
+					]]></Remove>
+				</Data>
+			</Field>
+			<Field title="Tags" type="[]string">
+				<Path>tags</Path>
+			</Field>
+			<Field title="Salary" type="float64" optional="true">
+				<Path>salary.min</Path>
+			</Field>
+			<Field title="Remote" type="bool">
+				<Path>remote</Path>
+			</Field>
+		</Field>
+	</Field>
+</Pattern>
+`
+
+func TestApplyJson(t *testing.T) {
+	pn := NewPatterns(nil)
+	assert.NoError(t, pn.LoadXml(pn.Tree, []byte(jsonPatternStr), "jobs.xml"))
+	assert.NoError(t, pn.LoadXml(pn.Tree, []byte(xmlStr), "test.xml"))
+
+	data, err := pn.ApplyContext(&Context{URL: "https://example.com/api/jobs", Mime: "json"}, strings.NewReader(jsonDoc))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"jobs.xml": map[string]interface{}{
+			"Jobs": map[string]interface{}{
+				"Total": 1204,
+				"Item": []interface{}{
+					map[string]interface{}{"Id": 17, "Title": "Go developer", "Tags": []interface{}{"go", "sql"}, "Salary": 80000.5, "Remote": true},
+					map[string]interface{}{"Id": 19, "Title": "Designer", "Tags": []interface{}{"ux"}, "Salary": nil, "Remote": false},
+				},
+			},
+		},
+	}, data)
+
+	assert.True(t, pn.Tree.Applies(&Context{URL: "https://example.com/api/jobs", Mime: "json"}))
+	assert.False(t, pn.Tree.Applies(&Context{URL: "https://example.com/other", Mime: "json"}))
+	assert.False(t, pn.Tree.Applies(&Context{URL: "https://example.com/api/jobs", Mime: "xml"}))
+
+	// HTML patterns are not applied to JSON documents and vice versa
+	data, err = pn.ApplyContext(&Context{URL: "https://news.ycombinator.com/jobs", Mime: "json"}, strings.NewReader(jsonDoc))
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+
+	data, err = pn.Apply("https://example.com/api/jobs", strings.NewReader(`<html></html>`))
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+}
+
+func TestMimeType(t *testing.T) {
+	assert.Equal(t, "html", MimeType("text/html; charset=utf-8"))
+	assert.Equal(t, "json", MimeType("application/json"))
+	assert.Equal(t, "xml", MimeType("application/rss+xml"))
+	assert.Equal(t, "", MimeType("application/ld+json"))
+	assert.Equal(t, "", MimeType("application/problem+json"))
+	assert.Equal(t, "", MimeType("image/svg+xml"))
+	assert.Equal(t, "", MimeType("image/png"))
+}
+
+func TestApplyJsonNoPath(t *testing.T) {
+	pn := NewPatterns(nil)
+	// root field is struct by default
+	assert.NoError(t, pn.LoadXml(pn.Tree, []byte(`
+<Pattern mime="json">
+	<Field title="Jobs">
+		<Field title="Total" type="string">
+			<Path>total</Path>
+		</Field>
+	</Field>
+</Pattern>`), "jobs.xml"))
+
+	data, err := pn.ApplyContext(&Context{URL: "https://example.com/api/jobs", Mime: "json"}, strings.NewReader(jsonDoc))
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+}
@@ -0,0 +1,389 @@
+// patterns
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var jsonSlice = regexp.MustCompile(`^-?\d*\s*:\s*-?\d*$`)
+
+// Compiled JSONPath expression. Supported syntax:
+//
+//	$.store.book[0].title   absolute path
+//	@.title, title          path relative to current node
+//	$..author               recursive descent
+//	[*], .*                 all elements or values
+//	['a','b'], [0,2]        names or indexes union
+//	[1:3], [-1:]            array slices
+//	[?(@.type)]             elements having value
+//	[?(@.type == 'Job')]    elements matching value (== and != are supported)
+type JsonPath struct {
+	expr     string
+	absolute bool
+	steps    []*jsonStep
+}
+
+type jsonStep struct {
+	// "..name" like steps
+	recursive bool
+
+	wildcard bool
+	names    []string
+	indexes  []int
+
+	// array slice [start:end]
+	slice      bool
+	start, end *int
+
+	filter *jsonFilter
+}
+
+type jsonFilter struct {
+	path *JsonPath
+	// empty operator means existence test
+	op    string
+	value interface{}
+}
+
+func CompileJsonPath(expr string) (*JsonPath, error) {
+	p := &JsonPath{expr: expr}
+	s := strings.TrimSpace(expr)
+
+	switch {
+	case strings.HasPrefix(s, "$"):
+		p.absolute = true
+		s = s[1:]
+	case strings.HasPrefix(s, "@"):
+		s = s[1:]
+	case s != "" && s[0] != '.' && s[0] != '[':
+		// bare name relative to current node
+		s = "." + s
+	}
+
+	for len(s) > 0 {
+		step := &jsonStep{}
+		switch {
+		case strings.HasPrefix(s, ".."):
+			step.recursive = true
+			s = s[2:]
+			if strings.HasPrefix(s, "[") {
+				rest, err := parseJsonBracket(step, s)
+				if err != nil {
+					return nil, errors.New(err.Error() + "\n JSONPath: " + expr)
+				}
+				s = rest
+			} else {
+				s = parseJsonName(step, s)
+			}
+		case strings.HasPrefix(s, "."):
+			s = parseJsonName(step, s[1:])
+		case strings.HasPrefix(s, "["):
+			rest, err := parseJsonBracket(step, s)
+			if err != nil {
+				return nil, errors.New(err.Error() + "\n JSONPath: " + expr)
+			}
+			s = rest
+		default:
+			return nil, errors.New("Unexpected '" + s + "'\n JSONPath: " + expr)
+		}
+
+		if !step.wildcard && !step.slice && step.filter == nil && len(step.names) == 0 && len(step.indexes) == 0 {
+			return nil, errors.New("Empty step\n JSONPath: " + expr)
+		}
+		p.steps = append(p.steps, step)
+	}
+	return p, nil
+}
+
+func (p *JsonPath) String() string {
+	return p.expr
+}
+
+// parses ".name" or ".*" step; returns the rest of expression
+func parseJsonName(step *jsonStep, s string) string {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		end = len(s)
+	}
+	name := strings.TrimSpace(s[:end])
+	if name == "*" {
+		step.wildcard = true
+	} else if name != "" {
+		step.names = []string{name}
+	}
+	return s[end:]
+}
+
+// parses "[...]" step; returns the rest of expression
+func parseJsonBracket(step *jsonStep, s string) (string, error) {
+	end := matchingBracket(s)
+	if end < 0 {
+		return "", errors.New("Missing ']'")
+	}
+	body := strings.TrimSpace(s[1:end])
+	rest := s[end+1:]
+
+	switch {
+	case body == "*":
+		step.wildcard = true
+	case strings.HasPrefix(body, "?(") && strings.HasSuffix(body, ")"):
+		filter, err := parseJsonFilter(body[2 : len(body)-1])
+		if err != nil {
+			return "", err
+		}
+		step.filter = filter
+	case jsonSlice.MatchString(body):
+		step.slice = true
+		parts := strings.SplitN(body, ":", 2)
+		for i, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return "", errors.New("Bad slice index " + part)
+			}
+			if i == 0 {
+				step.start = &n
+			} else {
+				step.end = &n
+			}
+		}
+	default:
+		for _, part := range strings.Split(body, ",") {
+			part = strings.TrimSpace(part)
+			if isQuoted(part) {
+				step.names = append(step.names, part[1:len(part)-1])
+			} else {
+				n, err := strconv.Atoi(part)
+				if err != nil {
+					return "", errors.New("Bad index " + part)
+				}
+				step.indexes = append(step.indexes, n)
+			}
+		}
+	}
+	return rest, nil
+}
+
+// index of "]" closing the first "[", quotes and nested brackets are skipped
+func matchingBracket(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isQuoted(s string) bool {
+	return len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0]
+}
+
+func parseJsonFilter(s string) (*jsonFilter, error) {
+	f := &jsonFilter{}
+	left := s
+	for _, op := range []string{"==", "!="} {
+		if i := strings.Index(s, op); i >= 0 {
+			f.op = op
+			left = s[:i]
+			literal := strings.TrimSpace(s[i+len(op):])
+			if isQuoted(literal) {
+				f.value = literal[1 : len(literal)-1]
+			} else if err := json.Unmarshal([]byte(literal), &f.value); err != nil {
+				return nil, errors.New("Bad filter value " + literal)
+			}
+			switch f.value.(type) {
+			case map[string]interface{}, []interface{}:
+				return nil, errors.New("Filter value should be string, number, boolean or null: " + literal)
+			}
+			break
+		}
+	}
+
+	var err error
+	f.path, err = CompileJsonPath(strings.TrimSpace(left))
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Evaluates path; absolute paths start at "root", relative ones at "current"
+func (p *JsonPath) Evaluate(root, current interface{}) []interface{} {
+	nodes := []interface{}{current}
+	if p.absolute {
+		nodes = []interface{}{root}
+	}
+
+	for _, step := range p.steps {
+		next := make([]interface{}, 0)
+		for _, node := range nodes {
+			if step.recursive {
+				for _, n := range jsonDescendants(node) {
+					next = append(next, step.apply(root, n)...)
+				}
+			} else {
+				next = append(next, step.apply(root, node)...)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+func (step *jsonStep) apply(root, node interface{}) []interface{} {
+	res := make([]interface{}, 0)
+	switch n := node.(type) {
+	case map[string]interface{}:
+		switch {
+		case step.wildcard:
+			for _, key := range sortedKeys(n) {
+				res = append(res, n[key])
+			}
+		case step.filter != nil:
+			for _, key := range sortedKeys(n) {
+				if step.filter.test(root, n[key]) {
+					res = append(res, n[key])
+				}
+			}
+		default:
+			for _, name := range step.names {
+				if val, ok := n[name]; ok {
+					res = append(res, val)
+				}
+			}
+		}
+	case []interface{}:
+		switch {
+		case step.wildcard:
+			res = append(res, n...)
+		case step.filter != nil:
+			for _, val := range n {
+				if step.filter.test(root, val) {
+					res = append(res, val)
+				}
+			}
+		case step.slice:
+			start, end := 0, len(n)
+			if step.start != nil {
+				start = normalizeIndex(*step.start, len(n))
+			}
+			if step.end != nil {
+				end = normalizeIndex(*step.end, len(n))
+			}
+			for i := start; i < end; i++ {
+				res = append(res, n[i])
+			}
+		default:
+			for _, i := range step.indexes {
+				if i < 0 {
+					i += len(n)
+				}
+				if i >= 0 && i < len(n) {
+					res = append(res, n[i])
+				}
+			}
+		}
+	}
+	return res
+}
+
+func (f *jsonFilter) test(root, node interface{}) bool {
+	found := f.path.Evaluate(root, node)
+	if f.op == "" {
+		return len(found) > 0
+	}
+
+	equal := false
+	for _, v := range found {
+		if jsonEqual(v, f.value) {
+			equal = true
+			break
+		}
+	}
+	if f.op == "!=" {
+		return !equal
+	}
+	return equal
+}
+
+func jsonEqual(a, b interface{}) bool {
+	an, aok := jsonFloat(a)
+	bn, bok := jsonFloat(b)
+	if aok && bok {
+		return an == bn
+	}
+	return a == b
+}
+
+func jsonFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func normalizeIndex(i, length int) int {
+	if i < 0 {
+		i += length
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > length {
+		return length
+	}
+	return i
+}
+
+// node itself and all nested values, depth-first
+func jsonDescendants(node interface{}) []interface{} {
+	res := []interface{}{node}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(n) {
+			res = append(res, jsonDescendants(n[key])...)
+		}
+	case []interface{}:
+		for _, val := range n {
+			res = append(res, jsonDescendants(val)...)
+		}
+	}
+	return res
+}
+
+// object keys in stable order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
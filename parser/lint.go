@@ -0,0 +1,590 @@
+// patterns
+package parser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// problem of pattern file found by Lint
+type Issue struct {
+	File string
+	// 0 if unknown
+	Line    int
+	Message string
+}
+
+func (i *Issue) String() string {
+	if i.Line > 0 {
+		return i.File + ":" + strconv.Itoa(i.Line) + ": " + i.Message
+	}
+	return i.File + ": " + i.Message
+}
+
+// Lint loads patterns directory the way Patterns.Load does, but rather than logging the first compile error
+// of every pattern it reports all problems found, with file and line where it's known:
+//
+//	XML/YAML syntax errors, unknown elements and attributes (YAML keys), unknown types
+//	missing or empty Paths, several Paths of struct fields (only the first one is used)
+//	Include/Exclude regexes which never match or are declared twice
+//	duplicate titles of sub-fields
+//	patterns of the same mime applying to the same URLs
+//
+// files and directories starting with "_" (fragments and base patterns) are checked, but not compiled by themselves;
+// issues are ordered by file and line
+func Lint(path string) []*Issue {
+	l := &linter{dirs: newFragmentDirs(path)}
+	l.dir(path, nil, true)
+	l.overlaps()
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		if l.issues[i].File != l.issues[j].File {
+			return l.issues[i].File < l.issues[j].File
+		}
+		return l.issues[i].Line < l.issues[j].Line
+	})
+	return l.issues
+}
+
+type linter struct {
+	issues []*Issue
+
+	// fragments patterns are read with
+	dirs *fragmentDirs
+
+	// compiled patterns checked for overlapping URL rules
+	patterns []*lintedPattern
+}
+
+type lintedPattern struct {
+	file string
+	// line of URL rules
+	line int
+	mime string
+	url  *CompiledRegexRules
+}
+
+func (l *linter) add(file string, line int, message string) {
+	l.issues = append(l.issues, &Issue{File: file, Line: line, Message: message})
+}
+
+func (l *linter) reported(file string, line int) bool {
+	for _, issue := range l.issues {
+		if issue.File == file && issue.Line == line {
+			return true
+		}
+	}
+	return false
+}
+
+// "applied" is false for directories starting with "_"
+func (l *linter) dir(path string, fragments *fragmentSet, applied bool) {
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		l.add(path, 0, err.Error())
+		return
+	}
+
+	count := len(l.issues)
+	for _, ext := range []string{"xml", "yaml"} {
+		file := path + "/" + fragmentsFile + "." + ext
+		if data, err := ioutil.ReadFile(file); err == nil {
+			l.syntax(file, data, reflect.TypeOf(Fragments{}))
+		}
+	}
+	next, err := fragments.loadDir(path)
+	if err != nil {
+		if len(l.issues) == count {
+			l.add(path, 0, err.Error())
+		}
+		// patterns are checked with fragments of parent directories
+		next = fragments
+	}
+	l.dirs.sets[filepath.Clean(path)] = next
+
+	for _, f := range files {
+		itemName := f.Name()
+		file := path + "/" + itemName
+		if f.IsDir() {
+			if itemName == goldenDir {
+				// documents of golden files tests
+				continue
+			}
+			l.dir(file, next, applied && !strings.HasPrefix(itemName, "_"))
+		} else if strings.HasPrefix(itemName, fragmentsFile+".") {
+			continue
+		} else if hasExt(itemName, "xml") || hasExt(itemName, "yaml") {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				l.add(file, 0, err.Error())
+				continue
+			}
+			l.pattern(file, data, applied && !strings.HasPrefix(itemName, "_"))
+		}
+	}
+}
+
+// checks pattern file; "applied" patterns are compiled as well
+func (l *linter) pattern(file string, data []byte, applied bool) {
+	lines, ok := l.syntax(file, data, reflect.TypeOf(Map{}))
+	if !ok {
+		return
+	}
+	m := &Map{}
+	if hasExt(file, "xml") {
+		m.UnmarshalXml(data)
+	} else {
+		m.UnmarshalYaml(data)
+	}
+
+	// fields of extending pattern could only override base ones
+	partial := m.Extends != ""
+	l.rules(file, lines, "URL[0]", m.URL)
+	titles := make(map[string]string)
+	if m.Field != nil {
+		l.field(file, lines, "Field[0]", m.Field, false, partial, titles)
+	} else if !partial {
+		l.add(file, 0, "Pattern has no Field")
+	}
+
+	if !applied {
+		return
+	}
+	next, err := readPattern(file, l.dirs, nil)
+	if err != nil {
+		l.add(file, 0, err.Error())
+		return
+	}
+	node := &PatternNode{}
+	if err = (&Patterns{}).add(node, next, "pattern", file); err != nil {
+		// compile errors name the field, like: "Failed to compile Title. ..."
+		line := 0
+		for title, addr := range titles {
+			if strings.HasPrefix(err.Error(), "Failed to compile "+title+". ") {
+				line = lines.elem(addr)
+			}
+		}
+		if line == 0 || !l.reported(file, line) {
+			l.add(file, line, err.Error())
+		}
+		return
+	}
+	compiled := (*node)["pattern"].(*CompiledMap)
+	l.patterns = append(l.patterns, &lintedPattern{file: file, line: lines.elem("URL[0]"), mime: compiled.mime, url: compiled.url})
+}
+
+// checks field declaration and its sub-fields; "grouped" is set for sub-fields of Submatch groups struct,
+// "partial" for fields which could take type and Path elsewhere (fragments, base patterns)
+func (l *linter) field(file string, lines *lintLines, addr string, f *Field, grouped, partial bool, titles map[string]string) {
+	line := lines.elem(addr)
+	name := "Field " + f.Title
+	if _, ok := titles[f.Title]; !ok {
+		titles[f.Title] = addr
+	}
+	partial = partial || f.Use != ""
+
+	var t *Type
+	if f.Type != "" {
+		var err error
+		if t, err = CompileType(f.Type); err != nil {
+			l.add(file, line, name+": "+err.Error())
+		}
+	} else if !partial && addr != "Field[0]" {
+		// root field is struct by default
+		l.add(file, line, name+" has no type")
+	}
+
+	// lines of Path with their indexes
+	paths := make([]int, 0)
+	for i, x := range strings.Split(f.Path, "\n") {
+		if strings.TrimSpace(x) != "" {
+			paths = append(paths, i)
+		}
+	}
+	structured := t != nil && t.base().kind == reflect.Map
+	if len(paths) == 0 {
+		if pathLine := lines.elem(addr + "/Path[0]"); pathLine > 0 || f.Path != "" {
+			l.add(file, pathLine, name+" has empty Path")
+		} else if !partial && !grouped && !structured && f.Capture == "" && f.Expr == "" {
+			l.add(file, line, name+" has no Path")
+		}
+	}
+
+	groups := false
+	if f.Data != nil && f.Data.Submatch != "" {
+		if re, err := regexp.Compile(f.Data.Submatch); err == nil {
+			for _, group := range re.SubexpNames() {
+				groups = groups || group != ""
+			}
+		}
+	}
+	if t != nil && t.base().kind == reflect.Struct && !groups && f.Source == "" && len(paths) > 1 {
+		l.add(file, lines.text(addr+"/Path[0]", paths[1]), name+" is struct, only its first Path is used")
+	}
+	l.rules(file, lines, addr+"/Data[0]", f.Data)
+
+	seen := make(map[string]bool)
+	for i, child := range f.Field {
+		childAddr := addr + "/Field[" + strconv.Itoa(i) + "]"
+		if seen[child.Title] {
+			l.add(file, lines.elem(childAddr), name+" has duplicate sub-field "+child.Title)
+		}
+		seen[child.Title] = true
+		l.field(file, lines, childAddr, child, groups, partial, titles)
+	}
+
+	if f.Switch == nil {
+		return
+	}
+	switchAddr := addr + "/Switch[0]"
+	variantTitle := f.Switch.Title
+	if variantTitle == "" {
+		variantTitle = defaultVariantTitle
+	}
+	if seen[variantTitle] {
+		l.add(file, lines.elem(switchAddr), name+" Switch title "+variantTitle+" collides with sub-field")
+	}
+	for i, w := range f.Switch.When {
+		whenAddr := switchAddr + "/When[" + strconv.Itoa(i) + "]"
+		l.rules(file, lines, whenAddr+"/Data[0]", w.Data)
+		// branch sub-fields share struct with common ones
+		branch := make(map[string]bool)
+		for title := range seen {
+			branch[title] = true
+		}
+		branch[variantTitle] = true
+		for j, child := range w.Field {
+			childAddr := whenAddr + "/Field[" + strconv.Itoa(j) + "]"
+			if branch[child.Title] {
+				l.add(file, lines.elem(childAddr), name+" has duplicate sub-field "+child.Title+" in When "+w.Name)
+			}
+			branch[child.Title] = true
+			l.field(file, lines, childAddr, child, false, partial, titles)
+		}
+	}
+}
+
+// checks Include and Exclude regexes of rules
+func (l *linter) rules(file string, lines *lintLines, addr string, r *RegexRules) {
+	if r == nil {
+		return
+	}
+	for _, rule := range []struct{ name, data string }{{"Include", r.Include}, {"Exclude", r.Exclude}} {
+		seen := make(map[string]bool)
+		for i, x := range strings.Split(rule.data, "\n") {
+			x = strings.TrimSpace(x)
+			if x == "" {
+				continue
+			}
+			line := lines.text(addr+"/"+rule.name+"[0]", i)
+			re, err := regexp.Compile(x)
+			switch {
+			case err != nil:
+				l.add(file, line, rule.name+" regex error: "+err.Error())
+			case seen[x]:
+				l.add(file, line, rule.name+" regex "+x+" is declared twice")
+			case len(regexExamples(re)) == 0:
+				l.add(file, line, rule.name+" regex "+x+" never matches")
+			}
+			seen[x] = true
+		}
+	}
+}
+
+// reports pairs of patterns of the same mime whose URL rules accept the same URL
+func (l *linter) overlaps() {
+	for i, a := range l.patterns {
+		for _, b := range l.patterns[i+1:] {
+			if a.mime != b.mime {
+				continue
+			}
+			if len(a.url.Include) == 0 && len(b.url.Include) == 0 {
+				l.add(a.file, a.line, "URL rules overlap with "+b.file+", both apply to every URL")
+				continue
+			}
+			if url, ok := overlap(a.url, b.url); ok {
+				l.add(a.file, a.line, "URL rules overlap with "+b.file+", both apply to "+url)
+			} else if url, ok := overlap(b.url, a.url); ok {
+				l.add(a.file, a.line, "URL rules overlap with "+b.file+", both apply to "+url)
+			}
+		}
+	}
+}
+
+// finds URL matching Include regex of "a" accepted by both rules
+func overlap(a, b *CompiledRegexRules) (string, bool) {
+	for _, r := range a.Include {
+		for _, url := range regexExamples(r) {
+			if a.Test([]byte(url)) && b.Test([]byte(url)) {
+				return url, true
+			}
+		}
+	}
+	return "", false
+}
+
+// limits number of examples tried for every regex
+const maxRegexExamples = 16
+
+// short strings matching regex; empty if regex can never match, like "^a$b"
+func regexExamples(re *regexp.Regexp) []string {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	res := make([]string, 0)
+	for _, s := range syntaxExamples(parsed.Simplify()) {
+		if re.MatchString(s) {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// candidates are checked against the whole regex, since anchors and word boundaries match empty string here
+func syntaxExamples(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return nil
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCharClass:
+		if r, ok := classRune(re.Rune); ok {
+			return []string{string(r)}
+		}
+		return nil
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		// keeps URLs like "https://example.com" readable when dots aren't escaped
+		return []string{"."}
+	case syntax.OpCapture, syntax.OpPlus:
+		return syntaxExamples(re.Sub[0])
+	case syntax.OpStar, syntax.OpQuest:
+		return limitExamples(append([]string{""}, syntaxExamples(re.Sub[0])...))
+	case syntax.OpRepeat:
+		res := []string{""}
+		for i := 0; i < re.Min; i++ {
+			res = productExamples(res, syntaxExamples(re.Sub[0]))
+		}
+		return res
+	case syntax.OpConcat:
+		res := []string{""}
+		for _, sub := range re.Sub {
+			res = productExamples(res, syntaxExamples(sub))
+		}
+		return res
+	case syntax.OpAlternate:
+		res := make([]string, 0)
+		for _, sub := range re.Sub {
+			res = append(res, syntaxExamples(sub)...)
+		}
+		return limitExamples(res)
+	}
+	// empty string, anchors and word boundaries
+	return []string{""}
+}
+
+func productExamples(heads, tails []string) []string {
+	res := make([]string, 0)
+	for _, head := range heads {
+		for _, tail := range tails {
+			res = append(res, head+tail)
+		}
+	}
+	return limitExamples(res)
+}
+
+func limitExamples(examples []string) []string {
+	if len(examples) > maxRegexExamples {
+		return examples[:maxRegexExamples]
+	}
+	return examples
+}
+
+// picks readable rune of character class ranges, like "a" for [^/]
+func classRune(ranges []rune) (rune, bool) {
+	if len(ranges) == 0 {
+		return 0, false
+	}
+	for _, r := range []rune{'a', '0', '-', '_'} {
+		for i := 0; i+1 < len(ranges); i += 2 {
+			if ranges[i] <= r && r <= ranges[i+1] {
+				return r, true
+			}
+		}
+	}
+	for i := 0; i+1 < len(ranges); i += 2 {
+		if ranges[i+1] >= '!' {
+			if ranges[i] < '!' {
+				return '!', true
+			}
+			return ranges[i], true
+		}
+	}
+	return ranges[0], true
+}
+
+// lines of XML elements addressed like "Field[0]/Field[2]/Path[0]" (index among siblings of the same name);
+// nil for YAML files, so every line is 0
+type lintLines struct {
+	elems map[string]int
+	// lines text of elements starts at
+	texts map[string]int
+}
+
+func (ls *lintLines) elem(addr string) int {
+	if ls == nil {
+		return 0
+	}
+	return ls.elems[addr]
+}
+
+// line of text line "index" of element
+func (ls *lintLines) text(addr string, index int) int {
+	if ls == nil {
+		return 0
+	}
+	if line, ok := ls.texts[addr]; ok {
+		return line + index
+	}
+	return ls.elems[addr]
+}
+
+// reports syntax errors and unknown names of file declaring "root" type; false if file can't be parsed
+func (l *linter) syntax(file string, data []byte, root reflect.Type) (*lintLines, bool) {
+	if hasExt(file, "yaml") {
+		err := yaml.UnmarshalStrict(data, reflect.New(root).Interface())
+		if typeErr, ok := err.(*yaml.TypeError); ok {
+			// unknown keys, values of wrong types
+			for _, message := range typeErr.Errors {
+				l.add(file, yamlLine(message), message)
+			}
+			return nil, true
+		}
+		if err != nil {
+			l.add(file, yamlLine(err.Error()), err.Error())
+			return nil, false
+		}
+		return nil, true
+	}
+
+	lines := &lintLines{elems: make(map[string]int), texts: make(map[string]int)}
+	type element struct {
+		addr string
+		// nil for unknown elements
+		t      reflect.Type
+		counts map[string]int
+	}
+	stack := make([]*element, 0)
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		offset := d.InputOffset()
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line := 0
+			if syntaxErr, ok := err.(*xml.SyntaxError); ok {
+				line = syntaxErr.Line
+			}
+			l.add(file, line, err.Error())
+			return nil, false
+		}
+		line := 1 + bytes.Count(data[:offset], []byte("\n"))
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			name := token.Name.Local
+			next := &element{t: root, counts: make(map[string]int)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				next.addr = name + "[" + strconv.Itoa(parent.counts[name]) + "]"
+				if parent.addr != "" {
+					next.addr = parent.addr + "/" + next.addr
+				}
+				parent.counts[name]++
+
+				next.t = nil
+				if parent.t != nil {
+					_, elems := xmlNames(parent.t)
+					if next.t = elems[name]; next.t == nil {
+						l.add(file, line, "Unknown element <"+name+">")
+					}
+				}
+			}
+			lines.elems[next.addr] = line
+
+			if next.t != nil {
+				attrs, _ := xmlNames(next.t)
+				for _, attr := range token.Attr {
+					if attr.Name.Space == "" && attr.Name.Local != "xmlns" && !attrs[attr.Name.Local] {
+						l.add(file, line, "Unknown attribute "+attr.Name.Local+" of <"+name+">")
+					}
+				}
+			}
+			stack = append(stack, next)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				addr := stack[len(stack)-1].addr
+				if _, ok := lines.texts[addr]; !ok {
+					lines.texts[addr] = line
+				}
+			}
+		}
+	}
+	return lines, true
+}
+
+var yamlLineRegexp = regexp.MustCompile(`line (\d+)`)
+
+func yamlLine(message string) int {
+	if found := yamlLineRegexp.FindStringSubmatch(message); found != nil {
+		line, _ := strconv.Atoi(found[1])
+		return line
+	}
+	return 0
+}
+
+// attribute and element names of XML declaration type, like Field; elements are mapped to their types
+func xmlNames(t reflect.Type) (map[string]bool, map[string]reflect.Type) {
+	attrs := make(map[string]bool)
+	elems := make(map[string]reflect.Type)
+	if t.Kind() != reflect.Struct {
+		// text elements, like Path
+		return attrs, elems
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("xml")
+		if field.PkgPath != "" || tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		if name == "" {
+			name = field.Name
+		}
+		if containsString(parts[1:], "attr") {
+			attrs[name] = true
+			continue
+		}
+		elem := field.Type
+		for elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Slice {
+			elem = elem.Elem()
+		}
+		elems[name] = elem
+	}
+	return attrs, elems
+}
@@ -0,0 +1,102 @@
+// patterns
+package parser
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"site/Body.xml": `<Pattern mime="html">
+	<URL>
+		<Include><![CDATA[
+			^https://example.com/jobs/[0-9]+
+			^https://example.com/jobs/[0-9]+
+			^https://example.com$/about
+		]]></Include>
+	</URL>
+	<Field title="Body" type="struct">
+		<Path>//body</Path>
+		<Field title="Title" type="strnig" optinal="true">
+			<Path>//h1</Path>
+		</Field>
+		<Field title="Salary" type="int">
+			<Path> </Path>
+		</Field>
+		<Field title="Title" type="string">
+			<Path>//h2</Path>
+		</Field>
+		<Field title="Company" type="string"/>
+		<Field title="Place" type="struct">
+			<Path>
+				//div[@class='place']
+				//span[@class='place']
+			</Path>
+			<Field title="City" type="string"><Path>.//b</Path></Field>
+		</Field>
+		<Include>^/jobs/</Include>
+	</Field>
+</Pattern>`,
+		"site/Detail.xml": `<Pattern mime="html">
+	<URL>
+		<Include>^https://example\.com/jobs/[0-9]+$</Include>
+	</URL>
+	<Field title="Body" type="struct">
+		<Path>//body</Path>
+	</Field>
+</Pattern>`,
+		"site/Feed.xml": `<Pattern mime="xml">
+	<Field title="Feed" type="struct">
+		<Path>//channel</Path>
+	</Field>
+</Pattern>`,
+		"site/Item.xml": `<Pattern mime="html">
+	<URL>
+		<Include>^https://example.com/jobs</Include>
+	</URL>
+	<Field title="Item" type="[]struct">
+		<Path>//li</Path>
+		<Field title="Title" type="string"><Path>.//h2</Path></Field>
+	</Field>
+</Pattern>`,
+		"site/Broken.xml": `<Pattern mime="html">
+	<Field title="Body" type="struct">
+		<Path>//body</Path>
+	</Feld>
+</Pattern>`,
+		"site/Feed.yaml": "mime: json\nfield:\n  title: Feed\n  type: struct\n  path: $.items\n  optinal: true\n",
+	})
+	defer os.RemoveAll(dir)
+
+	issues := make([]string, 0)
+	for _, issue := range Lint(dir) {
+		issues = append(issues, issue.String())
+	}
+	body := dir + "/site/Body.xml:"
+	assert.Equal(t, []string{
+		body + "5: Include regex ^https://example.com/jobs/[0-9]+ is declared twice",
+		body + "6: Include regex ^https://example.com$/about never matches",
+		body + "11: Unknown attribute optinal of <Field>",
+		body + "11: Field Title: Unrecognized type strnig",
+		body + "15: Field Salary has empty Path",
+		body + "17: Field Body has duplicate sub-field Title",
+		body + "20: Field Company has no Path",
+		body + "24: Field Place is struct, only its first Path is used",
+		body + "28: Unknown element <Include>",
+		dir + "/site/Broken.xml:4: XML syntax error on line 4: element <Field> closed by </Feld>",
+		// Feed patterns are applied to XML and JSON documents only
+		dir + "/site/Detail.xml:2: URL rules overlap with " + dir + "/site/Item.xml, both apply to https://example.com/jobs/0",
+		dir + "/site/Feed.yaml:6: line 6: field optinal not found in type parser.Field",
+	}, issues)
+
+	// rules are compared on short URLs their regexes match
+	assert.Equal(t, []string{"https://example.com/jobs/0"}, regexExamples(regexp.MustCompile(`^https://example\.com/jobs/[0-9]+$`)))
+	assert.Empty(t, regexExamples(regexp.MustCompile(`a^b`)))
+	url, ok := overlap(&CompiledRegexRules{Include: []*regexp.Regexp{regexp.MustCompile(`^https://example\.com/jobs[^/]`)}},
+		&CompiledRegexRules{Include: []*regexp.Regexp{regexp.MustCompile(`^https://example\.com/jobs/`)}})
+	assert.False(t, ok, url)
+}
@@ -2,19 +2,22 @@
 package parser
 
 import (
+	"bytes"
 	"encoding/xml"
 	"errors"
 	//"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/antchfx/xpath"
-	"github.com/antchfx/xquery/html"
 	"golang.org/x/net/html"
 	"gopkg.in/yaml.v2"
 	//"gopkg.in/xmlpath.v2"
@@ -25,6 +28,29 @@ var lineSplit = regexp.MustCompile(`\n+`)
 type Type struct {
 	kind   reflect.Kind
 	isHtml bool
+
+	// element type; only set if kind is reflect.Slice
+	elem *Type
+
+	// strip thousands separators, currency symbols etc. before conversion
+	lenient bool
+
+	// decimal separator: '.' or ','
+	decimal byte
+
+	// "time" and "date" types: values are parsed with layouts and emitted as RFC 3339
+	isTime   bool
+	isDate   bool
+	layouts  []string
+	location *time.Location
+
+	// "url" type: values are resolved against document URL
+	isUrl      bool
+	urlOptions urlOptions
+
+	// "jsonld", "microdata" or "opengraph" structured data; values are maps
+	structured string
+	schemas    []string
 }
 
 type PatternNode map[string]interface{}
@@ -58,12 +84,36 @@ func (f *Map) MarshalYaml() ([]byte, error) {
 	return yaml.Marshal(&f)
 }
 
+// Compiles type name into Type. Slice types are declared with "[]" prefix,
+// e.g. "[]string", "[]struct" or nested "[][]string"
 func CompileType(typeName string) (*Type, error) {
 	t := &Type{}
 
+	if strings.HasPrefix(typeName, "[]") {
+		elem, err := CompileType(strings.TrimPrefix(typeName, "[]"))
+		if err != nil {
+			return nil, err
+		}
+		if elem.kind == reflect.Slice && (elem.base().kind == reflect.Struct || elem.base().kind == reflect.Map) {
+			return nil, errors.New("Unsupported type " + typeName + ". Nested slices are only allowed for scalar types")
+		}
+		if elem.depth() > 1 {
+			return nil, errors.New("Unsupported type " + typeName + ". Slices can't be nested more than twice")
+		}
+		t.kind = reflect.Slice
+		t.elem = elem
+		return t, nil
+	}
+
 	switch typeName {
 	case "int":
 		t.kind = reflect.Int
+	case "int64":
+		t.kind = reflect.Int64
+	case "uint":
+		t.kind = reflect.Uint
+	case "bool":
+		t.kind = reflect.Bool
 	case "string":
 		t.kind = reflect.String
 	case "float64":
@@ -73,24 +123,97 @@ func CompileType(typeName string) (*Type, error) {
 	case "html":
 		t.kind = reflect.String
 		t.isHtml = true
+	case "time":
+		t.kind = reflect.String
+		t.isTime = true
+	case "date":
+		t.kind = reflect.String
+		t.isTime = true
+		t.isDate = true
+	case "url":
+		t.kind = reflect.String
+		t.isUrl = true
+	case "jsonld", "microdata", "opengraph":
+		t.kind = reflect.Map
+		t.structured = typeName
+	case "":
+		return nil, errors.New("Missing type")
 	default:
-		t.kind = reflect.Struct
-		//return t, errors.New("Unrecognized type " + typeName)
+		return nil, errors.New("Unrecognized type " + typeName)
 	}
 	return t, nil
 }
 
+// returns innermost (non-slice) type
+func (t *Type) base() *Type {
+	if t.kind == reflect.Slice {
+		return t.elem.base()
+	}
+	return t
+}
+
+// returns number of slice levels: 0 for "string", 1 for "[]string", 2 for "[][]string"
+func (t *Type) depth() int {
+	if t.kind == reflect.Slice {
+		return t.elem.depth() + 1
+	}
+	return 0
+}
+
+// returns type name in the same form it's declared in patterns
+func (t *Type) String() string {
+	switch t.kind {
+	case reflect.Slice:
+		return "[]" + t.elem.String()
+	case reflect.Int:
+		return "int"
+	case reflect.Int64:
+		return "int64"
+	case reflect.Uint:
+		return "uint"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Float64:
+		return "float64"
+	case reflect.Struct:
+		return "struct"
+	case reflect.Map:
+		return t.structured
+	case reflect.String:
+		if t.isHtml {
+			return "html"
+		}
+		if t.isDate {
+			return "date"
+		}
+		if t.isTime {
+			return "time"
+		}
+		if t.isUrl {
+			return "url"
+		}
+		return "string"
+	}
+	return t.kind.String()
+}
+
 type Map struct {
 	//	Title   string `xml:"title,attr"`
 	Storage string `xml:"storage,attr,omitempty"`
 	Field   *Field
 	URL     *RegexRules
-	Mime    string `xml:"mime,attr"`
+
+	// document type pattern applies to: "html", "json" or "xml"
+	Mime string `xml:"mime,attr"`
+
+	// base pattern file, relative to this one; see extends.go
+	Extends string `xml:"extends,attr,omitempty"`
 }
 
 type CompiledMap struct {
 	title   string
 	storage string
+	mime    string
 	field   *CompiledField
 	url     *CompiledRegexRules
 }
@@ -105,20 +228,8 @@ func (p *Patterns) LoadXml(el *PatternNode, data []byte, itemName string) error
 	err := next_pattern.UnmarshalXml(data)
 	if err != nil {
 		return err
-	} else {
-		// set default type for root element
-		if next_pattern.Field.Type == "" {
-			next_pattern.Field.Type = "struct"
-		}
-		compiledPattern, err := next_pattern.Compile()
-		if err != nil {
-			return err
-		}
-
-		map[string]interface{}(*el)[itemName] = compiledPattern
-
 	}
-	return nil
+	return p.add(el, next_pattern, itemName, itemName)
 }
 
 func (p *Patterns) LoadYaml(el *PatternNode, data []byte, itemName string) error {
@@ -126,18 +237,32 @@ func (p *Patterns) LoadYaml(el *PatternNode, data []byte, itemName string) error
 	err := next_pattern.UnmarshalYaml(data)
 	if err != nil {
 		return err
-	} else {
-		// set default type for root element
-		if next_pattern.Field.Type == "" {
-			next_pattern.Field.Type = "struct"
-		}
-		compiledPattern, err := next_pattern.Compile()
-		if err != nil {
-			return err
-		}
+	}
+	return p.add(el, next_pattern, itemName, itemName)
+}
+
+// compiles pattern and adds it to the tree; "file" is used in errors
+func (p *Patterns) add(el *PatternNode, next_pattern *Map, itemName, file string) error {
+	if next_pattern.Extends != "" {
+		return errors.New("Pattern " + file + " extends " + next_pattern.Extends + ", which is only supported by Patterns.Load")
+	}
+
+	// patterns read by Load have fragments resolved already, others can't use them
+	next_pattern, err := (*fragmentSet)(nil).resolve(next_pattern)
+	if err != nil {
+		return errors.New("Failed to resolve fragments of " + file + ". " + err.Error())
+	}
 
-		map[string]interface{}(*el)[itemName] = compiledPattern
+	// set default type for root element
+	if next_pattern.Field.Type == "" {
+		next_pattern.Field.Type = "struct"
 	}
+	compiledPattern, err := next_pattern.Compile()
+	if err != nil {
+		return err
+	}
+
+	map[string]interface{}(*el)[itemName] = compiledPattern
 	return nil
 }
 
@@ -146,34 +271,41 @@ func (p *Patterns) LoadTree(path string) error {
 }
 
 func (p *Patterns) Load(el *PatternNode, path string) error {
+	return p.load(el, path, newFragmentDirs(path))
+}
+
+// "dirs" are fragments of patterns directories, patterns are resolved with them while read
+func (p *Patterns) load(el *PatternNode, path string, dirs *fragmentDirs) error {
 	files, err := ioutil.ReadDir(path)
 	if err != nil {
 		return err
 	}
+
+	if _, err = dirs.get(path); err != nil {
+		return err
+	}
+
 	for _, f := range files {
 		itemName := f.Name()
+		if strings.HasPrefix(itemName, "_") {
+			// fragments and base patterns are not applied by themselves
+			continue
+		}
+
 		if f.IsDir() {
 			new_el := &PatternNode{}
-			err := p.Load(new_el, path+"/"+itemName)
+			err := p.load(new_el, path+"/"+itemName, dirs)
 			if err != nil {
 				p.Log.Println(err)
 			}
 			map[string]interface{}(*el)[itemName] = new_el
-		} else {
-			data, err := ioutil.ReadFile(path + "/" + itemName)
+		} else if hasExt(itemName, "xml") || hasExt(itemName, "yaml") {
+			next_pattern, err := readPattern(path+"/"+itemName, dirs, nil)
+			if err == nil {
+				err = p.add(el, next_pattern, itemName, path+"/"+itemName)
+			}
 			if err != nil {
-				p.Log.Println(err)
-			} else {
-				var err error
-				if hasExt(itemName, "xml") {
-					err = p.LoadXml(el, data, itemName)
-				} else if hasExt(itemName, "yaml") {
-					err = p.LoadYaml(el, data, itemName)
-				}
-
-				if err != nil {
-					p.Log.Println("Pattern "+path+"/"+itemName+" compilation error", err)
-				}
+				p.Log.Println("Pattern "+path+"/"+itemName+" compilation error", err)
 			}
 		}
 	}
@@ -181,55 +313,209 @@ func (p *Patterns) Load(el *PatternNode, path string) error {
 }
 
 func (p *Map) Compile() (*CompiledMap, error) { //(interface{}, error) {
-	if p.Mime == "html" {
-		m := &CompiledMap{}
+	switch p.Mime {
+	case "html", "json", "xml":
+		m := &CompiledMap{mime: p.Mime}
 		var err error
 		m.url, err = p.URL.Compile()
 		if err != nil {
 			return nil, err
 		}
 
-		m.field, err = p.Field.Compile()
+		m.field, err = p.Field.compile(p.Mime)
 		if err != nil {
 			return nil, err
 		}
+
+		if err = m.field.checkCaptures(m.url.IncludeGroups()); err != nil {
+			return nil, err
+		}
+		if err = m.field.checkVariables(m.url.IncludeGroups()); err != nil {
+			return nil, err
+		}
 		return m, nil
 	}
-	return nil, nil
+	return nil, errors.New("Unsupported pattern mime \"" + p.Mime + "\"")
 }
 
 func ByteToKind(t reflect.Kind, data []byte) (interface{}, error) {
 	switch t {
+	case reflect.String:
+		return string(data), nil
 	case reflect.Int:
-		val, err := strconv.Atoi(string(data))
+		val, err := strconv.Atoi(strings.TrimSpace(string(data)))
 		if err != nil {
 			return nil, err
 		}
 		return int(val), nil
-	case reflect.String:
-		return string(data), nil
+	case reflect.Int64:
+		val, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return val, nil
+	case reflect.Uint:
+		val, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 0)
+		if err != nil {
+			return nil, err
+		}
+		return uint(val), nil
 	case reflect.Float64:
-		//val, err := strconv.FormatFloat(string(data), )
-		//return float64(data)
-	case reflect.Slice:
-		//		return []interface{}(data)
-	case reflect.Struct:
-		//		return interface{}(data)
+		val, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+		if err != nil {
+			return nil, err
+		}
+		return val, nil
+	case reflect.Bool:
+		val, err := strconv.ParseBool(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, err
+		}
+		return val, nil
 	}
-	return nil, nil
+	return nil, errors.New("Can't convert data to " + t.String())
+}
+
+// Set number format hints used by Convert. Decimal separator could be "." (default) or ","
+func (t *Type) SetFormat(lenient bool, decimal string) error {
+	switch decimal {
+	case "", ".":
+		t.decimal = '.'
+	case ",":
+		t.decimal = ','
+	default:
+		return errors.New("Unrecognized decimal separator " + decimal)
+	}
+	t.lenient = lenient
+	return nil
+}
+
+// Converts data to the type kind taking number format hints into account
+func (t *Type) Convert(data []byte) (interface{}, error) {
+	return t.convert(nil, data)
+}
+
+// same as Convert, but relative dates are anchored to context date
+func (t *Type) convert(ctx *Context, data []byte) (interface{}, error) {
+	if t.isTime {
+		return t.parseTime(data, ctx.now())
+	}
+	if t.isUrl {
+		return t.resolveUrl(ctx, data)
+	}
+
+	switch t.kind {
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Float64:
+		var err error
+		if data, err = t.normalizeNumber(data); err != nil {
+			return nil, err
+		}
+	case reflect.Bool:
+		if t.lenient {
+			switch strings.ToLower(strings.TrimSpace(string(data))) {
+			case "yes", "y", "on":
+				return true, nil
+			case "no", "n", "off", "":
+				return false, nil
+			}
+		}
+	}
+	return ByteToKind(t.kind, data)
+}
+
+// brings number like "$ 1,299.00" or "1.299,00 €" to "1299.00"; lenient mode only strips whitespace,
+// currency symbols and thousands separators and allows a leading sign, anything else (exponents, ranges
+// like "10-20", suffixes like "1.5k") is an error rather than a different number
+func (t *Type) normalizeNumber(data []byte) ([]byte, error) {
+	decimal, thousands := byte('.'), byte(',')
+	if t.decimal == ',' {
+		decimal, thousands = ',', '.'
+	}
+
+	data = bytes.TrimSpace(data)
+	if !t.lenient {
+		return bytes.Replace(data, []byte{decimal}, []byte{'.'}, -1), nil
+	}
+
+	res := make([]byte, 0, len(data))
+	for _, r := range string(data) {
+		switch {
+		case r >= '0' && r <= '9':
+			res = append(res, byte(r))
+		case r == rune(decimal):
+			res = append(res, '.')
+		case (r == '-' || r == '+') && len(res) == 0:
+			res = append(res, byte(r))
+		case r == rune(thousands), unicode.IsSpace(r), unicode.Is(unicode.Sc, r):
+		default:
+			return nil, errors.New("Can't convert " + strconv.Quote(string(data)) + " to number")
+		}
+	}
+	return res, nil
 }
 
 func (p *CompiledMap) ApplyHtml(url string, context *html.Node) interface{} {
-	// source URL should be either empty or fit current URL pattern
+	return p.ApplyHtmlContext(&Context{URL: url}, context)
+}
+
+func (p *CompiledMap) ApplyHtmlContext(ctx *Context, context *html.Node) interface{} {
+	return p.applyHtml(ctx, context, nil)
+}
+
+// Same as ApplyHtmlContext, but also reports which paths matched and why values were dropped
+func (p *CompiledMap) ApplyHtmlWithReport(ctx *Context, context *html.Node) (interface{}, *Report) {
+	report := newReport(p.field.title)
+	return p.applyHtml(ctx, context, report), report
+}
+
+// Applies pattern to parsed document; returns nil if document mime differs from pattern's one
+func (p *CompiledMap) ApplyDocument(ctx *Context, doc *Document) interface{} {
+	return p.apply(ctx, doc, nil)
+}
 
+func (p *CompiledMap) applyHtml(ctx *Context, context *html.Node, report *Report) interface{} {
+	return p.apply(ctx, &Document{Mime: "html", Html: context}, report)
+}
+
+// applies pattern to document of the same mime; report could be nil
+func (p *CompiledMap) apply(ctx *Context, doc *Document, report *Report) interface{} {
+	if doc.Mime != p.mime {
+		return nil
+	}
+
+	// source URL should be either empty or fit current URL pattern
+	url := ctx.url()
 	if url != "" {
-		if !p.url.Test([]byte(url)) {
+		if ok, reason := p.url.Check([]byte(url)); !ok {
+			report.add(DiagUrl, reason, []byte(url))
 			return nil
 		}
 	}
 
+	// named groups of URL are available to fields with "capture" attribute
+	ctx = ctx.withCaptures(p.url.Captures([]byte(url)))
+
+	// missing required="fail-document" field discards whole result
+	ctx = ctx.withFailure()
+
 	// retrieve data for root field
-	data := p.field.Retrieve(context)
+	var data interface{}
+	switch doc.Mime {
+	case "json":
+		data = p.field.retrieveJson(ctx, doc.Json, doc.Json, report)
+	case "xml":
+		data = p.field.retrieveXml(ctx, doc.Xml, report)
+	default:
+		// relative URLs are resolved against <base href> if any
+		ctx = ctx.withDocument(doc.Html)
+		data = p.field.retrieve(ctx, doc.Html, report)
+	}
+
+	if reason := ctx.failed(); reason != "" {
+		report.add(DiagRequired, "document failed: "+reason, nil)
+		return nil
+	}
+
 	if data != nil {
 		n := make(map[string]interface{})
 		n[p.field.title] = data
@@ -240,29 +526,81 @@ func (p *CompiledMap) ApplyHtml(url string, context *html.Node) interface{} {
 }
 
 func (p *Patterns) Apply(url string, content io.Reader) (map[string]interface{}, error) {
-	//data, err := xmlpath.ParseHTML(content)
-	data, err := htmlquery.Parse(content)
+	return p.ApplyContext(&Context{URL: url}, content)
+}
+
+// Applies patterns to response body; request URL, "Content-Type" and "Date" headers are taken into account
+func (p *Patterns) ApplyResponse(resp *http.Response) (map[string]interface{}, error) {
+	return p.ApplyContext(NewResponseContext(resp), resp.Body)
+}
+
+// Parses content according to context mime ("html" by default) and applies patterns to it
+func (p *Patterns) ApplyContext(ctx *Context, content io.Reader) (map[string]interface{}, error) {
+	doc, err := ParseDocument(ctx.mime(), content)
 	if err != nil {
 		return nil, err
 	}
 
-	return p.Tree.ApplyPatterns(url, data), nil
+	return p.Tree.ApplyDocument(ctx, doc), nil
+}
+
+// Same as Apply, but also returns reports for every pattern URL fits,
+// keyed by pattern path like: "startupgigs/stackoverflow.com/Item.xml"
+func (p *Patterns) ApplyWithReport(url string, content io.Reader) (map[string]interface{}, map[string]*Report, error) {
+	return p.ApplyContextWithReport(&Context{URL: url}, content)
+}
+
+// Same as ApplyContext, but also returns reports for every pattern of context mime URL fits
+func (p *Patterns) ApplyContextWithReport(ctx *Context, content io.Reader) (map[string]interface{}, map[string]*Report, error) {
+	doc, err := ParseDocument(ctx.mime(), content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reports := make(map[string]*Report)
+	return p.Tree.applyPatterns(ctx, doc, "", reports), reports, nil
 }
 
 // Applies XML patterns to input (URL "address" and HTML "content").
 // Returns map with result data.
 func (pn *PatternNode) ApplyPatterns(url string, data *html.Node /*content io.Reader*/) map[string]interface{} {
+	return pn.ApplyPatternsContext(&Context{URL: url}, data)
+}
+
+func (pn *PatternNode) ApplyPatternsContext(ctx *Context, data *html.Node) map[string]interface{} {
+	return pn.ApplyDocument(ctx, &Document{Mime: "html", Html: data})
+}
+
+// Applies patterns of the same mime as document
+func (pn *PatternNode) ApplyDocument(ctx *Context, doc *Document) map[string]interface{} {
+	return pn.applyPatterns(ctx, doc, "", nil)
+}
+
+// reports are collected if "reports" map isn't nil
+func (pn *PatternNode) applyPatterns(ctx *Context, doc *Document, prefix string, reports map[string]*Report) map[string]interface{} {
 	var el map[string]interface{}
 	for key, val := range *pn {
 		if pattern, ok := val.(*CompiledMap); ok {
-			if res := pattern.ApplyHtml(url, data); res != nil {
+			if pattern.mime != doc.Mime {
+				continue
+			}
+
+			var report *Report
+			if reports != nil {
+				report = newReport(pattern.field.title)
+			}
+			if res := pattern.apply(ctx, doc, report); res != nil {
 				if el == nil {
 					el = make(map[string]interface{})
 				}
 				el[key] = res
 			}
+			// patterns not fitting URL are of no interest
+			if report != nil && !report.urlRejected() {
+				reports[prefix+key] = report
+			}
 		} else if subPattern, ok := val.(*PatternNode); ok {
-			if res := subPattern.ApplyPatterns(url, data); res != nil {
+			if res := subPattern.applyPatterns(ctx, doc, prefix+key+"/", reports); res != nil {
 				if el == nil {
 					el = make(map[string]interface{})
 				}
@@ -275,6 +613,26 @@ func (pn *PatternNode) ApplyPatterns(url string, data *html.Node /*content io.Re
 	return el
 }
 
+// true if any pattern of context mime fits context URL
+func (pn *PatternNode) Applies(ctx *Context) bool {
+	for _, val := range *pn {
+		switch val := val.(type) {
+		case *CompiledMap:
+			if val.mime != ctx.mime() {
+				continue
+			}
+			if url := ctx.url(); url == "" || val.url.Test([]byte(url)) {
+				return true
+			}
+		case *PatternNode:
+			if val.Applies(ctx) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (pn *PatternNode) ListPatterns() []string {
 	res := []string{}
 	for key, val := range *pn {
@@ -291,14 +649,19 @@ func (pn *PatternNode) ListPatterns() []string {
 	return res
 }
 
-// CDATA to xml paths
+// CDATA to xml paths; lines prefixed with "css:" are CSS selectors
 func cdataToPaths(data string) ([]*xpath.Expr, error) {
 	paths := make([]*xpath.Expr, 0)
 	lines := lineSplit.Split(data, -1)
 	for _, x := range lines {
 		x := strings.TrimSpace(x)
 		if len(x) > 0 {
-			query, err := xpath.Compile(x)
+			expr, err := lineToXpath(x)
+			if err != nil {
+				return nil, err
+			}
+
+			query, err := xpath.Compile(expr)
 			/*
 				query, err := xmlpath.Compile(x)*/
 			if err != nil {
@@ -312,6 +675,35 @@ func cdataToPaths(data string) ([]*xpath.Expr, error) {
 	return paths, nil
 }
 
+// CSS selectors prefixed with "css:" are converted to XPath, other lines are XPath already
+func lineToXpath(x string) (string, error) {
+	if !strings.HasPrefix(x, cssPrefix) {
+		return x, nil
+	}
+	expr, err := CssToXpath(strings.TrimPrefix(x, cssPrefix))
+	if err != nil {
+		return "", errors.New(err.Error() + "\n Path: " + x)
+	}
+	return expr, nil
+}
+
+// CDATA to JSONPath expressions
+func cdataToJsonPaths(data string) ([]*JsonPath, error) {
+	paths := make([]*JsonPath, 0)
+	lines := lineSplit.Split(data, -1)
+	for _, x := range lines {
+		x := strings.TrimSpace(x)
+		if len(x) > 0 {
+			query, err := CompileJsonPath(x)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, query)
+		}
+	}
+	return paths, nil
+}
+
 // CDATA to regex rules
 func cdataToRegex(data string) ([]*regexp.Regexp, error) {
 	paths := make([]*regexp.Regexp, 0)
@@ -3,6 +3,7 @@ package parser
 
 import (
 	"fmt"
+	"reflect"
 	//"log"
 	"strings"
 	"testing"
@@ -305,3 +306,111 @@ func TestLoadYml(t *testing.T) {
 	assert.Equal(t, m.field.field[1].title, "Title")
 	assert.Equal(t, m.field.field[1].path[0].String(), "a[contains(@href, 'item?id=')]")
 }
+
+func TestCompileType(t *testing.T) {
+	for _, name := range []string{"int", "string", "float64", "struct", "html", "[]string", "[]struct", "[]html", "[][]string", "[][]html"} {
+		tp, err := CompileType(name)
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, name, tp.String())
+		}
+	}
+
+	tp, _ := CompileType("[]string")
+	assert.Equal(t, reflect.Slice, tp.kind)
+	assert.Equal(t, reflect.String, tp.base().kind)
+	assert.Equal(t, 1, tp.depth())
+
+	for _, name := range []string{"", "[]", "strnig", "[]foo", "[][]struct", "[][][]string"} {
+		_, err := CompileType(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestRetrieve_sliceTypes(t *testing.T) {
+	n, _ := htmlquery.Parse(strings.NewReader(`
+	<html>
+		<body>
+			<ul><li>a</li><li>b</li></ul>
+			<ul><li>c</li></ul>
+			<p>1,2,3</p>
+			<p>4,5</p>
+		</body>
+	</html>
+	`))
+
+	f := &Field{
+		Title: "Item",
+		Type:  "[]struct",
+		Path:  "//ul",
+		Field: []*Field{
+			&Field{Title: "Entry", Type: "[]string", Path: "li"},
+		},
+	}
+	cf, err := f.Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"Entry": []interface{}{"a", "b"}},
+		map[string]interface{}{"Entry": []interface{}{"c"}},
+	}, cf.Retrieve(n))
+
+	f = &Field{
+		Title: "Numbers",
+		Type:  "[][]int",
+		Path:  "//p",
+		Data:  &RegexRules{Submatch: `(\d+)`},
+	}
+	cf, err = f.Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		[]interface{}{1, 2, 3},
+		[]interface{}{4, 5},
+	}, cf.Retrieve(n))
+
+	f = &Field{Title: "Bad", Type: "[]strnig", Path: "//p"}
+	_, err = f.Compile()
+	assert.Error(t, err)
+}
+
+func TestConvert(t *testing.T) {
+	var cases = []struct {
+		typeName string
+		lenient  bool
+		decimal  string
+		in       string
+		out      interface{}
+	}{
+		{"int", false, "", " 42 ", 42},
+		{"int", true, "", "1,299", 1299},
+		{"int64", true, "", "$ 1,299", int64(1299)},
+		{"uint", true, "", "12 345", uint(12345)},
+		{"int", true, "", "-$1,299", -1299},
+		{"float64", false, "", "3.25", 3.25},
+		{"float64", false, ",", "3,25", 3.25},
+		{"float64", true, "", "$1,299.50", 1299.5},
+		{"float64", true, ",", "1.299,50 €", 1299.5},
+		{"bool", false, "", " true", true},
+		{"bool", true, "", "Yes", true},
+	}
+
+	for _, c := range cases {
+		tp, _ := CompileType(c.typeName)
+		assert.NoError(t, tp.SetFormat(c.lenient, c.decimal))
+		val, err := tp.Convert([]byte(c.in))
+		if assert.NoError(t, err, c.in) {
+			assert.Equal(t, c.out, val, c.in)
+		}
+	}
+
+	tp, _ := CompileType("int")
+	_, err := tp.Convert([]byte("1,299"))
+	assert.Error(t, err)
+
+	// lenient mode doesn't turn exponents, ranges and suffixes into other numbers
+	for _, bad := range []string{"1e5", "10-20", "1.5k", "12 items", "5-"} {
+		tp, _ := CompileType("float64")
+		assert.NoError(t, tp.SetFormat(true, ""))
+		_, err := tp.Convert([]byte(bad))
+		assert.Error(t, err, bad)
+	}
+	assert.Error(t, tp.SetFormat(false, ";"))
+}
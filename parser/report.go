@@ -0,0 +1,157 @@
+// patterns
+package parser
+
+import "unicode/utf8"
+
+// diagnostic kinds
+const (
+	// URL doesn't fit pattern
+	DiagUrl = "url"
+
+	// value rejected by Include/Exclude rules
+	DiagRejected = "rejected"
+
+	// value can't be converted to field type
+	DiagConversion = "conversion"
+
+	// node can't be rendered to HTML
+	DiagRender = "render"
+
+	// required child field is missing: struct is dropped, kept with null value
+	// or document fails depending on required level
+	DiagRequired = "required"
+
+	// value is missing, field default is used
+	DiagDefault = "default"
+
+	// value violates field constraints
+	DiagInvalid = "invalid"
+
+	// struct path matched no nodes
+	DiagNoMatch = "nomatch"
+)
+
+// max length of offending text kept in diagnostics
+const maxDiagnosticText = 200
+
+// Report on field retrieval: which paths matched and why values were dropped
+type Report struct {
+	// field title
+	Field string `json:"field"`
+
+	// path alternatives tried, with number of nodes found
+	Paths []*PathReport `json:"paths,omitempty"`
+
+	// path alternative the value was taken from
+	Matched string `json:"matched,omitempty"`
+
+	// number of values retrieved
+	Values int `json:"values"`
+
+	Diagnostics []*Diagnostic `json:"diagnostics,omitempty"`
+
+	// sub-fields reports
+	Fields []*Report `json:"fields,omitempty"`
+}
+
+type PathReport struct {
+	Path string `json:"path"`
+	Hits int    `json:"hits"`
+}
+
+type Diagnostic struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+
+	// offending text
+	Text string `json:"text,omitempty"`
+
+	// number of times the same diagnostic occured
+	Count int `json:"count"`
+}
+
+func newReport(title string) *Report {
+	return &Report{Field: title}
+}
+
+// returns report for sub-field; reports are shared by all items of multiple structs
+func (r *Report) child(title string) *Report {
+	if r == nil {
+		return nil
+	}
+	for _, c := range r.Fields {
+		if c.Field == title {
+			return c
+		}
+	}
+	c := newReport(title)
+	r.Fields = append(r.Fields, c)
+	return c
+}
+
+// counts nodes found by path
+func (r *Report) hit(path string, hits int) {
+	if r == nil {
+		return
+	}
+	for _, p := range r.Paths {
+		if p.Path == path {
+			p.Hits += hits
+			return
+		}
+	}
+	r.Paths = append(r.Paths, &PathReport{path, hits})
+}
+
+func (r *Report) matched(path string, values int) {
+	if r == nil {
+		return
+	}
+	r.Matched = path
+	r.Values += values
+}
+
+func (r *Report) add(kind, message string, text []byte) {
+	if r == nil {
+		return
+	}
+	t := truncateText(string(text), maxDiagnosticText)
+	for _, d := range r.Diagnostics {
+		if d.Kind == kind && d.Message == message && d.Text == t {
+			d.Count++
+			return
+		}
+	}
+	r.Diagnostics = append(r.Diagnostics, &Diagnostic{kind, message, t, 1})
+}
+
+// returns true if neither report nor its sub-reports have diagnostics
+func (r *Report) Ok() bool {
+	if r == nil {
+		return true
+	}
+	if len(r.Diagnostics) > 0 {
+		return false
+	}
+	for _, c := range r.Fields {
+		if !c.Ok() {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Report) urlRejected() bool {
+	return len(r.Diagnostics) > 0 && r.Diagnostics[0].Kind == DiagUrl
+}
+
+// cuts text longer than "max" bytes on character boundary and marks it with "..."
+func truncateText(t string, max int) string {
+	if len(t) <= max {
+		return t
+	}
+	for max > 0 && !utf8.RuneStart(t[max]) {
+		max--
+	}
+	return t[:max] + "..."
+}
@@ -0,0 +1,98 @@
+// patterns
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/antchfx/xquery/html"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetrieveWithReport(t *testing.T) {
+	f := &Field{
+		Title: "Item",
+		Type:  "[]struct",
+		Path:  "//li",
+		Field: []*Field{
+			&Field{
+				Title: "Title",
+				Type:  "string",
+				Path: `
+				b[@class='missing']
+				b
+				`,
+				Data: &RegexRules{Exclude: `^Sponsored`},
+			},
+			&Field{Title: "Price", Type: "int", Path: "i", Optional: true},
+		},
+	}
+	cf, err := f.Compile()
+	assert.NoError(t, err)
+
+	n, _ := htmlquery.Parse(strings.NewReader(`<html><body><ul>
+		<li><b>First</b><i>10</i></li>
+		<li><b>Sponsored</b><i>20</i></li>
+		<li><b>Third</b><i>n/a</i></li>
+	</ul></body></html>`))
+
+	res, report := cf.RetrieveWithReport(nil, n)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"Title": "First", "Price": 10},
+		map[string]interface{}{"Title": "Third", "Price": nil},
+	}, res)
+
+	assert.False(t, report.Ok())
+	assert.Equal(t, "//li", report.Matched)
+	assert.Equal(t, 2, report.Values)
+	assert.Equal(t, []*PathReport{&PathReport{"//li", 3}}, report.Paths)
+	assert.Equal(t, DiagRequired, report.Diagnostics[0].Kind)
+
+	title := report.Fields[0]
+	assert.Equal(t, "Title", title.Field)
+	assert.Equal(t, "b", title.Matched)
+	assert.Equal(t, []*PathReport{&PathReport{"b[@class='missing']", 0}, &PathReport{"b", 3}}, title.Paths)
+	assert.Equal(t, DiagRejected, title.Diagnostics[0].Kind)
+	assert.Equal(t, "Sponsored", title.Diagnostics[0].Text)
+
+	price := report.Fields[1]
+	assert.Equal(t, DiagConversion, price.Diagnostics[0].Kind)
+	assert.Equal(t, "n/a", price.Diagnostics[0].Text)
+}
+
+func TestApplyWithReport(t *testing.T) {
+	pn := NewPatterns(nil)
+	assert.NoError(t, pn.LoadXml(pn.Tree, []byte(xmlStr), "test.xml"))
+
+	data, reports, err := pn.ApplyWithReport("https://example.com", strings.NewReader(`<html></html>`))
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+	assert.Empty(t, reports)
+
+	data, reports, err = pn.ApplyWithReport("https://news.ycombinator.com/jobs", strings.NewReader(`<html></html>`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"test.xml": map[string]interface{}{"Item": []interface{}{}}}, data)
+	assert.Equal(t, DiagNoMatch, reports["test.xml"].Diagnostics[0].Kind)
+}
+
+func TestApplyContextWithReport(t *testing.T) {
+	pn := NewPatterns(nil)
+	assert.NoError(t, pn.LoadXml(pn.Tree, []byte(jsonPatternStr), "jobs.xml"))
+	assert.NoError(t, pn.LoadXml(pn.Tree, []byte(xmlStr), "test.xml"))
+
+	// only patterns of context mime are reported
+	data, reports, err := pn.ApplyContextWithReport(&Context{URL: "https://example.com/api/jobs", Mime: "json"}, strings.NewReader(jsonDoc))
+	assert.NoError(t, err)
+	assert.Contains(t, data, "jobs.xml")
+	assert.Len(t, reports, 1)
+	assert.Equal(t, "Jobs", reports["jobs.xml"].Field)
+	assert.True(t, reports["jobs.xml"].Values > 0)
+}
+
+func TestTruncateText(t *testing.T) {
+	assert.Equal(t, "abc", truncateText("abc", 3))
+	assert.Equal(t, "ab...", truncateText("abcd", 2))
+	// "é" takes 2 bytes
+	assert.Equal(t, "a...", truncateText("aéb", 2))
+	assert.Equal(t, "aé...", truncateText("aéb", 3))
+}
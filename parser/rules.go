@@ -11,6 +11,12 @@ type RegexRules struct {
 	Include  string
 	Exclude  string
 	Remove   string
+
+	// fragment rules are based on; see fragments.go
+	Use string `xml:"use,attr,omitempty"`
+
+	// fragment name (only in fragments file)
+	Name string `xml:"name,attr,omitempty"`
 }
 
 type CompiledRegexRules struct {
@@ -54,6 +60,12 @@ func (p *RegexRules) Compile() (*CompiledRegexRules, error) {
 }
 
 func (p *CompiledRegexRules) Test(s []byte) bool {
+	ok, _ := p.Check(s)
+	return ok
+}
+
+// same as Test, but also returns the reason of rejection
+func (p *CompiledRegexRules) Check(s []byte) (bool, string) {
 	if p != nil {
 		// URL must apply to at least one "include" patern
 		count := len(p.Include)
@@ -67,17 +79,17 @@ func (p *CompiledRegexRules) Test(s []byte) bool {
 				}
 			}
 			if count == 0 {
-				return false
+				return false, "no Include regex matched"
 			}
 		}
 
 		for _, r := range p.Exclude {
 			if r.Match(s) {
-				return false
+				return false, "Exclude regex matched: " + r.String()
 			}
 		}
 	}
-	return true
+	return true, ""
 }
 
 func (p *CompiledRegexRules) FindOne(s []byte) []byte {
@@ -117,3 +129,86 @@ func (p *CompiledRegexRules) Clean(s []byte) []byte {
 	}
 	return s
 }
+
+// named groups of the first Include regex matching s, like: ^https://example.com/jobs/(?P<JobId>\d+)
+func (p *CompiledRegexRules) Captures(s []byte) map[string]string {
+	if p == nil {
+		return nil
+	}
+	for _, r := range p.Include {
+		loc := r.FindSubmatchIndex(s)
+		if loc == nil {
+			continue
+		}
+		res := make(map[string]string)
+		for i, name := range r.SubexpNames() {
+			if name != "" && loc[2*i] >= 0 {
+				res[name] = string(s[loc[2*i]:loc[2*i+1]])
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+// names of named Include groups
+func (p *CompiledRegexRules) IncludeGroups() []string {
+	res := make([]string, 0)
+	if p != nil {
+		for _, r := range p.Include {
+			for _, name := range r.SubexpNames() {
+				if name != "" && !containsString(res, name) {
+					res = append(res, name)
+				}
+			}
+		}
+	}
+	return res
+}
+
+// names of named Submatch groups, like: (?P<city>...)
+func (p *CompiledRegexRules) Groups() []string {
+	res := make([]string, 0)
+	if p != nil && p.Submatch != nil {
+		for _, name := range p.Submatch.SubexpNames() {
+			if name != "" {
+				res = append(res, name)
+			}
+		}
+	}
+	return res
+}
+
+// named groups values of first Submatch found; groups not participating in match are omitted; nil if not found
+func (p *CompiledRegexRules) FindGroups(s []byte) map[string][]byte {
+	if p == nil || p.Submatch == nil {
+		return nil
+	}
+	loc := p.Submatch.FindSubmatchIndex(s)
+	if loc == nil {
+		return nil
+	}
+	return p.groups(s, loc)
+}
+
+// named groups values of every Submatch found
+func (p *CompiledRegexRules) FindAllGroups(s []byte) []map[string][]byte {
+	res := make([]map[string][]byte, 0)
+	if p == nil || p.Submatch == nil {
+		return res
+	}
+	for _, loc := range p.Submatch.FindAllSubmatchIndex(s, -1) {
+		res = append(res, p.groups(s, loc))
+	}
+	return res
+}
+
+func (p *CompiledRegexRules) groups(s []byte, loc []int) map[string][]byte {
+	res := make(map[string][]byte)
+	for i, name := range p.Submatch.SubexpNames() {
+		if name != "" && loc[2*i] >= 0 {
+			res[name] = s[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	return res
+}
@@ -0,0 +1,99 @@
+// patterns
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/antchfx/xquery/html"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindGroups(t *testing.T) {
+	rules, err := (&RegexRules{Submatch: `(?P<City>[^,;]+),\s*(?P<State>[A-Z]{2})(?:\s*\((?P<Remote>Remote)\))?`}).Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"City", "State", "Remote"}, rules.Groups())
+
+	assert.Equal(t, map[string][]byte{
+		"City":   []byte("Austin"),
+		"State":  []byte("TX"),
+		"Remote": []byte("Remote"),
+	}, rules.FindGroups([]byte("Austin, TX (Remote)")))
+	assert.Nil(t, rules.FindGroups([]byte("Anywhere")))
+
+	assert.Equal(t, []map[string][]byte{
+		{"City": []byte("Austin"), "State": []byte("TX")},
+		{"City": []byte(" Boston"), "State": []byte("MA"), "Remote": []byte("Remote")},
+	}, rules.FindAllGroups([]byte("Austin, TX; Boston, MA (Remote)")))
+}
+
+var groupsHtml = `<html><body>
+	<span class="location">Austin, TX (Remote)</span>
+	<span class="salary">$90,000 - $120,000</span>
+	<span class="location">Boston, MA</span>
+	<p class="offices">Kyiv, UA; Lviv, UA; Berlin, DE</p>
+</body></html>`
+
+func TestRetrieve_groups(t *testing.T) {
+	n, _ := htmlquery.Parse(strings.NewReader(groupsHtml))
+
+	f := &Field{
+		Title: "Location",
+		Type:  "[]struct",
+		Path:  "//span[@class='location']",
+		Data:  &RegexRules{Submatch: `(?P<City>[^,]+),\s*(?P<State>[A-Z]{2})(?:\s*\((?P<Remote>Remote)\))?`},
+	}
+	cf, err := f.Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"City": "Austin", "State": "TX", "Remote": "Remote"},
+		map[string]interface{}{"City": "Boston", "State": "MA", "Remote": nil},
+	}, cf.Retrieve(n))
+
+	f = &Field{
+		Title: "Salary",
+		Type:  "struct",
+		Path:  "//span[@class='salary']",
+		Data:  &RegexRules{Submatch: `(?P<Min>[\d$,]+)\s*-\s*(?P<Max>[\d$,]+)`},
+		Field: []*Field{
+			&Field{Title: "Min", Type: "int", Lenient: true},
+			&Field{Title: "Max", Type: "int", Lenient: true},
+		},
+	}
+	cf, err = f.Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"Min": 90000, "Max": 120000}, cf.Retrieve(n))
+
+	// every match within node
+	f = &Field{
+		Title:    "Office",
+		Type:     "struct",
+		Multiple: true,
+		Path:     "//p[@class='offices']",
+		Data: &RegexRules{
+			Submatch: `(?P<City>\w+),\s*(?P<Country>[A-Z]{2})`,
+			Exclude:  "Moscow",
+		},
+		Field: []*Field{
+			&Field{Title: "Country", Type: "string", Data: &RegexRules{Exclude: "DE"}},
+		},
+	}
+	cf, err = f.Compile()
+	assert.NoError(t, err)
+	val, report := cf.RetrieveWithReport(nil, n)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"City": "Kyiv", "Country": "UA"},
+		map[string]interface{}{"City": "Lviv", "Country": "UA"},
+	}, val)
+	assert.Equal(t, DiagRequired, report.Diagnostics[0].Kind)
+
+	for _, bad := range []*Field{
+		&Field{Title: "Location", Type: "struct", Path: "//span", Data: &RegexRules{Submatch: `(?P<City>\w+)`},
+			Field: []*Field{&Field{Title: "Town", Type: "string"}}},
+		&Field{Title: "Location", Type: "struct", Path: "//span", Data: &RegexRules{Submatch: `(?P<City>\w+)`},
+			Field: []*Field{&Field{Title: "City", Type: "string", Path: "//b"}}},
+	} {
+		_, err := bad.Compile()
+		assert.Error(t, err)
+	}
+}
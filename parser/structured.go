@@ -0,0 +1,328 @@
+// patterns
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/antchfx/xquery/html"
+	"golang.org/x/net/html"
+)
+
+// Structured data types: "jsonld" (schema.org JSON-LD scripts), "microdata" (itemscope/itemprop items)
+// and "opengraph" (og: meta tags). Values are maps, like:
+//
+//	{"@type": "JobPosting", "title": "Go developer", "hiringOrganization": {...}}
+//	{"og:title": "Go developer", "og:type": "article"}
+//
+// Set schema filter: comma separated list of schema.org types (like: JobPosting, Event);
+// for "opengraph" type it's matched against og:type
+func (t *Type) SetSchema(schema string) error {
+	if t.structured == "" {
+		if strings.TrimSpace(schema) != "" {
+			return errors.New("Schema is only allowed for jsonld, microdata and opengraph types")
+		}
+		return nil
+	}
+
+	t.schemas = nil
+	for _, s := range strings.Split(schema, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			t.schemas = append(t.schemas, s)
+		}
+	}
+	return nil
+}
+
+// tests item against schema filter
+func (t *Type) matchSchema(item map[string]interface{}) bool {
+	if len(t.schemas) == 0 {
+		return true
+	}
+
+	key := "@type"
+	if t.structured == "opengraph" {
+		key = "og:type"
+	}
+
+	types := make([]string, 0)
+	switch v := item[key].(type) {
+	case string:
+		types = append(types, strings.Fields(v)...)
+	case []interface{}:
+		for _, next := range v {
+			if s, ok := next.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+
+	for _, next := range types {
+		// "http://schema.org/JobPosting" or "schema:JobPosting"
+		if i := strings.LastIndexAny(next, "/:#"); i >= 0 {
+			next = next[i+1:]
+		}
+		for _, s := range t.schemas {
+			if strings.EqualFold(next, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Retrieves structured data items within nodes found by Path (whole document if there is no Path)
+func (f *CompiledField) retrieveStructured(ctx *Context, root *html.Node, report *Report) interface{} {
+	dataType := f.dataType.base()
+
+	if len(f.path) == 0 {
+		items := f.structuredItems(ctx, []*html.Node{root}, report)
+		report.hit(dataType.structured, len(items))
+		if result := f.structuredResult(items); result != nil {
+			report.matched(dataType.structured, len(items))
+			return result
+		}
+		report.add(DiagNoMatch, dataType.structured+" data not found", nil)
+		return nil
+	}
+
+	// check every Path provided
+	for _, query := range f.paths(ctx, report) {
+		scopes := htmlquery.Find(root, query.String())
+		report.hit(query.String(), len(scopes))
+
+		items := f.structuredItems(ctx, scopes, report)
+		if result := f.structuredResult(items); result != nil {
+			report.matched(query.String(), len(items))
+			return result
+		}
+	}
+	report.add(DiagNoMatch, dataType.structured+" data not found", nil)
+	return nil
+}
+
+// all items for multiple fields, first one otherwise
+func (f *CompiledField) structuredResult(items []interface{}) interface{} {
+	if len(items) == 0 {
+		return nil
+	}
+	if f.multiple {
+		return items
+	}
+	return items[0]
+}
+
+// finds items of field schema within scope nodes
+func (f *CompiledField) structuredItems(ctx *Context, scopes []*html.Node, report *Report) []interface{} {
+	dataType := f.dataType.base()
+
+	found := make([]map[string]interface{}, 0)
+	for _, scope := range scopes {
+		switch dataType.structured {
+		case "jsonld":
+			found = append(found, jsonLdItems(scope, report)...)
+		case "microdata":
+			found = append(found, microdataItems(ctx, scope)...)
+		case "opengraph":
+			if item := openGraphItem(scope); item != nil {
+				found = append(found, item)
+			}
+		}
+	}
+
+	res := make([]interface{}, 0)
+	for _, item := range found {
+		if !dataType.matchSchema(item) {
+			continue
+		}
+		if f.unique && containsValue(res, item) {
+			continue
+		}
+		res = append(res, item)
+	}
+	return res
+}
+
+func containsValue(list []interface{}, val interface{}) bool {
+	for _, v := range list {
+		if reflect.DeepEqual(v, val) {
+			return true
+		}
+	}
+	return false
+}
+
+// calls visit for node and all its descendant elements; visit returns false to skip element children
+func walkElements(node *html.Node, visit func(*html.Node) bool) {
+	if node.Type == html.ElementNode && !visit(node) {
+		return
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		walkElements(child, visit)
+	}
+}
+
+// JSON-LD items of <script type="application/ld+json"> within node;
+// top level arrays and "@graph" lists are expanded to their items
+func jsonLdItems(node *html.Node, report *Report) []map[string]interface{} {
+	res := make([]map[string]interface{}, 0)
+	walkElements(node, func(n *html.Node) bool {
+		if n.Data != "script" || !strings.EqualFold(strings.TrimSpace(htmlquery.SelectAttr(n, "type")), "application/ld+json") {
+			return true
+		}
+
+		text := htmlquery.InnerText(n)
+		decoder := json.NewDecoder(strings.NewReader(text))
+		// keep numbers as they are in document
+		decoder.UseNumber()
+		var data interface{}
+		if err := decoder.Decode(&data); err != nil {
+			report.add(DiagConversion, "bad JSON-LD: "+err.Error(), []byte(text))
+			return false
+		}
+
+		for _, item := range jsonItems(data) {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if graph, ok := obj["@graph"]; ok {
+				for _, next := range jsonItems(graph) {
+					if o, ok := next.(map[string]interface{}); ok {
+						res = append(res, o)
+					}
+				}
+				continue
+			}
+			res = append(res, obj)
+		}
+		return false
+	})
+	return res
+}
+
+// top level microdata items (itemscope elements which are not properties of other items) within node
+func microdataItems(ctx *Context, node *html.Node) []map[string]interface{} {
+	res := make([]map[string]interface{}, 0)
+	walkElements(node, func(n *html.Node) bool {
+		if !hasAttr(n, "itemscope") {
+			return true
+		}
+		if !hasAttr(n, "itemprop") || n == node {
+			res = append(res, microdataItem(ctx, n))
+		}
+		// nested items belong to this one
+		return false
+	})
+	return res
+}
+
+func microdataItem(ctx *Context, node *html.Node) map[string]interface{} {
+	item := make(map[string]interface{})
+	if itemType := strings.TrimSpace(htmlquery.SelectAttr(node, "itemtype")); itemType != "" {
+		item["@type"] = itemType
+	}
+	if id := strings.TrimSpace(htmlquery.SelectAttr(node, "itemid")); id != "" {
+		item["@id"] = id
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		walkElements(child, func(n *html.Node) bool {
+			if hasAttr(n, "itemprop") {
+				var val interface{}
+				if hasAttr(n, "itemscope") {
+					val = microdataItem(ctx, n)
+				} else {
+					val = microdataValue(ctx, n)
+				}
+				for _, name := range strings.Fields(htmlquery.SelectAttr(n, "itemprop")) {
+					addProperty(item, name, val)
+				}
+			}
+			// properties of nested items belong to them
+			return !hasAttr(n, "itemscope")
+		})
+	}
+	return item
+}
+
+// property value according to element: content, URL or text
+func microdataValue(ctx *Context, n *html.Node) string {
+	switch n.Data {
+	case "meta":
+		return htmlquery.SelectAttr(n, "content")
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		return resolveReference(ctx, htmlquery.SelectAttr(n, "src"))
+	case "a", "area", "link":
+		return resolveReference(ctx, htmlquery.SelectAttr(n, "href"))
+	case "object":
+		return resolveReference(ctx, htmlquery.SelectAttr(n, "data"))
+	case "data", "meter":
+		return htmlquery.SelectAttr(n, "value")
+	case "time":
+		if hasAttr(n, "datetime") {
+			return htmlquery.SelectAttr(n, "datetime")
+		}
+	}
+	return strings.Join(strings.Fields(htmlquery.InnerText(n)), " ")
+}
+
+// OpenGraph (og:, article:, profile: etc.) meta tags within node; nil if there are none
+func openGraphItem(node *html.Node) map[string]interface{} {
+	var item map[string]interface{}
+	walkElements(node, func(n *html.Node) bool {
+		if n.Data != "meta" {
+			return true
+		}
+		property := htmlquery.SelectAttr(n, "property")
+		if !strings.Contains(property, ":") {
+			return true
+		}
+		if item == nil {
+			item = make(map[string]interface{})
+		}
+		addProperty(item, strings.TrimSpace(property), htmlquery.SelectAttr(n, "content"))
+		return true
+	})
+	return item
+}
+
+// repeated properties are collected to list
+func addProperty(item map[string]interface{}, name string, val interface{}) {
+	prev, ok := item[name]
+	if !ok {
+		item[name] = val
+		return
+	}
+	if list, ok := prev.([]interface{}); ok {
+		item[name] = append(list, val)
+		return
+	}
+	item[name] = []interface{}{prev, val}
+}
+
+func hasAttr(n *html.Node, name string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resolves URL against document URL; returned as is if it can't
+func resolveReference(ctx *Context, s string) string {
+	s = strings.TrimSpace(s)
+	base, err := ctx.baseUrl()
+	if err != nil || base == nil || s == "" {
+		return s
+	}
+	ref, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	return base.ResolveReference(ref).String()
+}
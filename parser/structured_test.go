@@ -0,0 +1,117 @@
+// patterns
+package parser
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/antchfx/xquery/html"
+	"github.com/stretchr/testify/assert"
+)
+
+var structuredHtml = `<html><head>
+	<meta property="og:title" content="Go developer">
+	<meta property="og:type" content="article">
+	<meta property="og:image" content="https://example.com/1.png">
+	<meta property="og:image" content="https://example.com/2.png">
+	<meta name="description" content="Jobs">
+	<script type="application/ld+json">
+	{
+		"@context": "https://schema.org",
+		"@graph": [
+			{"@type": "Organization", "name": "Acme"},
+			{"@type": "JobPosting", "title": "Go developer", "baseSalary": 100000}
+		]
+	}
+	</script>
+	<script type="application/ld+json">[{"@type": ["JobPosting"], "title": "Designer"}]</script>
+	<script type="application/ld+json">{broken</script>
+</head><body>
+	<div itemscope itemtype="https://schema.org/JobPosting">
+		<h1 itemprop="title">Go developer</h1>
+		<a itemprop="url" href="/jobs/1">Apply</a>
+		<time itemprop="datePosted" datetime="2018-03-05">March 5</time>
+		<div itemprop="hiringOrganization" itemscope itemtype="https://schema.org/Organization">
+			<span itemprop="name">Acme</span>
+			<meta itemprop="sameAs" content="https://acme.com">
+		</div>
+		<span itemprop="skills">Go</span>
+		<span itemprop="skills">  SQL
+		</span>
+	</div>
+	<div itemscope itemtype="https://schema.org/Event"><span itemprop="name">Meetup</span></div>
+</body></html>`
+
+func TestRetrieveStructured(t *testing.T) {
+	n, _ := htmlquery.Parse(strings.NewReader(structuredHtml))
+	ctx := &Context{URL: "https://example.com/jobs"}
+
+	f := &Field{Title: "Jobs", Type: "[]jsonld", Schema: "JobPosting"}
+	cf, err := f.Compile()
+	assert.NoError(t, err)
+	val, report := cf.RetrieveWithReport(ctx, n)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"@type": "JobPosting", "title": "Go developer", "baseSalary": json.Number("100000")},
+		map[string]interface{}{"@type": []interface{}{"JobPosting"}, "title": "Designer"},
+	}, val)
+	assert.Equal(t, DiagConversion, report.Diagnostics[0].Kind)
+
+	f = &Field{Title: "Organization", Type: "jsonld", Schema: "Organization"}
+	cf, err = f.Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"@type": "Organization", "name": "Acme"}, cf.RetrieveContext(ctx, n))
+
+	f = &Field{Title: "Job", Type: "microdata", Schema: "JobPosting"}
+	cf, err = f.Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"@type":      "https://schema.org/JobPosting",
+		"title":      "Go developer",
+		"url":        "https://example.com/jobs/1",
+		"datePosted": "2018-03-05",
+		"hiringOrganization": map[string]interface{}{
+			"@type":  "https://schema.org/Organization",
+			"name":   "Acme",
+			"sameAs": "https://acme.com",
+		},
+		"skills": []interface{}{"Go", "SQL"},
+	}, cf.RetrieveContext(ctx, n))
+
+	f = &Field{Title: "Events", Type: "[]microdata", Path: "//body/div[2]"}
+	cf, err = f.Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"@type": "https://schema.org/Event", "name": "Meetup"},
+	}, cf.RetrieveContext(ctx, n))
+
+	f = &Field{Title: "OpenGraph", Type: "opengraph"}
+	cf, err = f.Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"og:title": "Go developer",
+		"og:type":  "article",
+		"og:image": []interface{}{"https://example.com/1.png", "https://example.com/2.png"},
+	}, cf.RetrieveContext(ctx, n))
+
+	f = &Field{Title: "Video", Type: "opengraph", Schema: "video.movie"}
+	cf, err = f.Compile()
+	assert.NoError(t, err)
+	val, report = cf.RetrieveWithReport(ctx, n)
+	assert.Nil(t, val)
+	assert.Equal(t, DiagNoMatch, report.Diagnostics[0].Kind)
+}
+
+func TestCompileStructured(t *testing.T) {
+	_, err := (&Field{Title: "Title", Type: "string", Schema: "JobPosting"}).Compile()
+	assert.Error(t, err)
+
+	_, err = (&Field{Title: "Job", Type: "jsonld", Field: []*Field{&Field{Title: "Title", Type: "string"}}}).Compile()
+	assert.Error(t, err)
+
+	_, err = (&Field{Title: "Jobs", Type: "[][]jsonld"}).Compile()
+	assert.Error(t, err)
+
+	_, err = (&Map{Mime: "json", Field: &Field{Title: "Job", Type: "jsonld"}}).Compile()
+	assert.Error(t, err)
+}
@@ -0,0 +1,170 @@
+// patterns
+package parser
+
+import (
+	"errors"
+
+	"github.com/antchfx/xpath"
+)
+
+// Variants of struct items, like sponsored cards and regular rows of the same list:
+//
+//	<Field title="Item" type="[]struct">
+//		<Path>//li</Path>
+//		<Field title="Title" type="string"><Path>.//h2</Path></Field>
+//		<Switch title="Kind">
+//			<When name="sponsored">
+//				<XData><Include>self::*[contains(@class, 'sponsored')]</Include></XData>
+//				<Field title="Sponsor" type="string"><Path>.//span[@class='by']</Path></Field>
+//			</When>
+//			<When name="regular">
+//				<Field title="Salary" type="int"><Path>.//span[@class='salary']</Path></Field>
+//			</When>
+//		</Switch>
+//	</Field>
+//
+// item takes the first branch whose XData (tested against item node) and Data (tested against
+// item text) rules pass, branch without rules matches any item; items no branch matches are dropped.
+// sub-fields of the struct are shared by every branch, branch name is stored to Switch title
+type Switch struct {
+	// title branch name is stored to; "Variant" by default
+	Title string `xml:"title,attr,omitempty"`
+
+	When []*When
+}
+
+type When struct {
+	// branch name
+	Name string `xml:"name,attr"`
+
+	// xpath rules item node is tested against (html and xml patterns)
+	XData *XpathRules
+
+	// regex rules item text is tested against
+	Data *RegexRules
+
+	// sub-fields of the branch
+	Field []*Field
+}
+
+const defaultVariantTitle = "Variant"
+
+type compiledVariant struct {
+	name  string
+	xdata *CompiledXpathRules
+	data  *CompiledRegexRules
+	field []*CompiledField
+}
+
+func (s *Switch) compile(f *Field, mime string) (string, []*compiledVariant, error) {
+	if s == nil {
+		return "", nil, nil
+	}
+
+	title := s.Title
+	if title == "" {
+		title = defaultVariantTitle
+	}
+	titles := []string{title}
+	for _, child := range f.Field {
+		if containsString(titles, child.Title) {
+			return "", nil, errors.New("Failed to compile " + f.Title + ". Field " + child.Title + " is declared twice")
+		}
+		titles = append(titles, child.Title)
+	}
+
+	if len(s.When) == 0 {
+		return "", nil, errors.New("Failed to compile " + f.Title + ". Switch has no When branches")
+	}
+
+	variants := make([]*compiledVariant, 0)
+	names := make([]string, 0)
+	for _, w := range s.When {
+		if w.Name == "" || containsString(names, w.Name) {
+			return "", nil, errors.New("Failed to compile " + f.Title + ". When name \"" + w.Name + "\" is missing or not unique")
+		}
+		names = append(names, w.Name)
+
+		if w.XData != nil && mime == "json" {
+			return "", nil, errors.New("Failed to compile " + f.Title + ". When " + w.Name + " can't test XData of JSON")
+		}
+
+		v := &compiledVariant{name: w.Name}
+		var err error
+		v.xdata, err = w.XData.Compile()
+		if err != nil {
+			return "", nil, errors.New("Failed to compile " + f.Title + ". When " + w.Name + ": " + err.Error())
+		}
+		v.data, err = w.Data.Compile()
+		if err != nil {
+			return "", nil, errors.New("Failed to compile " + f.Title + ". When " + w.Name + ": " + err.Error())
+		}
+
+		branchTitles := append([]string{}, titles...)
+		for _, field := range w.Field {
+			if containsString(branchTitles, field.Title) {
+				return "", nil, errors.New("Failed to compile " + f.Title + ". Field " + field.Title + " of When " + w.Name + " is declared twice")
+			}
+			branchTitles = append(branchTitles, field.Title)
+
+			compiledField, err := field.compile(mime)
+			if err != nil {
+				return "", nil, err
+			}
+			v.field = append(v.field, compiledField)
+		}
+		variants = append(variants, v)
+	}
+	return title, variants, nil
+}
+
+// first branch item matches; "nav" is nil for JSON items, "text" is called if branch tests it
+func (f *CompiledField) variant(nav xpath.NodeNavigator, text func() []byte, report *Report) (*compiledVariant, bool) {
+	if f.variants == nil {
+		return nil, true
+	}
+	for _, v := range f.variants {
+		if nav != nil && !v.xdata.Test(nav) {
+			continue
+		}
+		if len(v.data.Include) > 0 || len(v.data.Exclude) > 0 {
+			if !v.data.Test(text()) {
+				continue
+			}
+		}
+		return v, true
+	}
+	report.add(DiagRejected, "no When branch matched, item dropped", nil)
+	return nil, false
+}
+
+// every set of sub-fields struct could be made of
+func (f *CompiledField) fieldSets() [][]*CompiledField {
+	if f.variants == nil {
+		return [][]*CompiledField{f.field}
+	}
+	res := make([][]*CompiledField, 0, len(f.variants))
+	for _, v := range f.variants {
+		res = append(res, f.fields(v))
+	}
+	return res
+}
+
+// sub-fields of the struct along with sub-fields of every branch
+func (f *CompiledField) allFields() []*CompiledField {
+	res := append([]*CompiledField{}, f.field...)
+	for _, v := range f.variants {
+		res = append(res, v.field...)
+	}
+	return res
+}
+
+// sub-fields of the struct along with sub-fields of the branch
+func (f *CompiledField) fields(v *compiledVariant) []*CompiledField {
+	if v == nil {
+		return f.field
+	}
+	res := make([]*CompiledField, 0, len(f.field)+len(v.field))
+	res = append(res, f.field...)
+	return append(res, v.field...)
+}
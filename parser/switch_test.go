@@ -0,0 +1,94 @@
+// patterns
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var switchPatternStr = `
+<Pattern mime="html">
+	<Field title="Item" type="[]struct">
+		<Path>//li</Path>
+		<Field title="Title" type="string">
+			<Path>.//h2</Path>
+		</Field>
+		<Switch title="Kind">
+			<When name="sponsored">
+				<XData><Include>self::*[contains(@class, 'sponsored')]</Include></XData>
+				<Field title="Sponsor" type="string">
+					<Path>.//span[@class='by']</Path>
+				</Field>
+			</When>
+			<When name="ad">
+				<Data><Include>Advertisement</Include></Data>
+			</When>
+			<When name="regular">
+				<XData><Include>.//span[@class='salary']</Include></XData>
+				<Field title="Salary" type="int">
+					<Path>.//span[@class='salary']</Path>
+				</Field>
+			</When>
+		</Switch>
+	</Field>
+</Pattern>
+`
+
+var switchHtml = `<html><body><ul>
+	<li class="job sponsored"><h2>Go developer</h2><span class="by">Acme</span></li>
+	<li class="job"><h2>Designer</h2><span class="salary">90000</span></li>
+	<li class="banner"><h2>Advertisement</h2></li>
+	<li class="job"><h2>No salary</h2></li>
+</ul></body></html>`
+
+func TestRetrieve_switch(t *testing.T) {
+	p := NewPatterns(nil)
+	assert.NoError(t, p.LoadXml(p.Tree, []byte(switchPatternStr), "switch.xml"))
+
+	data, reports, err := p.ApplyWithReport("", strings.NewReader(switchHtml))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"switch.xml": map[string]interface{}{
+			"Item": []interface{}{
+				map[string]interface{}{"Kind": "sponsored", "Title": "Go developer", "Sponsor": "Acme"},
+				map[string]interface{}{"Kind": "regular", "Title": "Designer", "Salary": 90000},
+				map[string]interface{}{"Kind": "ad", "Title": "Advertisement"},
+			},
+		},
+	}, data)
+	assert.Equal(t, DiagRejected, reports["switch.xml"].Diagnostics[0].Kind)
+}
+
+func TestRetrieveJson_switch(t *testing.T) {
+	f := &Field{Title: "Item", Type: "[]struct", Path: "$.items[*]",
+		Field: []*Field{&Field{Title: "ID", Type: "int", Path: "id"}},
+		Switch: &Switch{When: []*When{
+			&When{Name: "job", Data: &RegexRules{Include: `"salary"`},
+				Field: []*Field{&Field{Title: "Salary", Type: "int", Path: "salary"}}},
+			&When{Name: "other"},
+		}},
+	}
+	cf, err := f.compile("json")
+	assert.NoError(t, err)
+
+	doc, err := ParseDocument("json", strings.NewReader(`{"items": [{"id": 1, "salary": 100}, {"id": 2}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"Variant": "job", "ID": 1, "Salary": 100},
+		map[string]interface{}{"Variant": "other", "ID": 2},
+	}, cf.retrieveJson(nil, doc.Json, doc.Json, nil))
+
+	for _, bad := range []*Switch{
+		&Switch{},
+		&Switch{When: []*When{&When{Name: "a"}, &When{Name: "a"}}},
+		&Switch{Title: "ID", When: []*When{&When{Name: "a"}}},
+		&Switch{When: []*When{&When{Name: "a", Field: []*Field{&Field{Title: "ID", Type: "int", Path: "id"}}}}},
+		&Switch{When: []*When{&When{Name: "a", XData: &XpathRules{Include: "self::li"}}}},
+	} {
+		f.Switch = bad
+		_, err := f.compile("json")
+		assert.Error(t, err)
+	}
+}
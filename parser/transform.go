@@ -0,0 +1,223 @@
+// patterns
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// Value transformations, one per line, applied in order after regex rules and before conversion:
+//
+//	trim                   strip leading and trailing spaces
+//	collapse_ws            replace whitespace runs with single space, trim
+//	lower, upper           change case
+//	replace "old" "new"    replace every occurrence
+//	split ","              split value into multiple values (for multiple fields or followed by join)
+//	join "|"               join values into one
+//	html_unescape          "&amp;" to "&" etc.
+//	strip_tags             remove HTML tags
+//	truncate 100           cut to number of characters
+//	default "n/a"          replace empty value
+//
+// arguments are double quoted (Go escapes, like "\n", are allowed), single quoted or bare words
+type CompiledTransform struct {
+	ops []transformOp
+}
+
+type transformOp struct {
+	name string
+	args []string
+	n    int
+}
+
+// number of arguments every operation takes
+var transformArgs = map[string]int{
+	"trim":          0,
+	"collapse_ws":   0,
+	"lower":         0,
+	"upper":         0,
+	"replace":       2,
+	"split":         1,
+	"join":          1,
+	"html_unescape": 0,
+	"strip_tags":    0,
+	"truncate":      1,
+	"default":       1,
+}
+
+func CompileTransform(data string) (*CompiledTransform, error) {
+	t := &CompiledTransform{}
+	for _, line := range lineSplit.Split(data, -1) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		words, err := splitArgs(line)
+		if err != nil {
+			return nil, errors.New(err.Error() + "\n Transform: " + line)
+		}
+
+		op := transformOp{name: words[0], args: words[1:]}
+		count, ok := transformArgs[op.name]
+		if !ok {
+			return nil, errors.New("Unrecognized transform " + op.name)
+		}
+		if len(op.args) != count {
+			return nil, errors.New("Transform " + op.name + " takes " + strconv.Itoa(count) + " argument(s)\n Transform: " + line)
+		}
+		if op.name == "truncate" {
+			op.n, err = strconv.Atoi(op.args[0])
+			if err != nil || op.n <= 0 {
+				return nil, errors.New("Transform truncate takes positive number\n Transform: " + line)
+			}
+		}
+		t.ops = append(t.ops, op)
+	}
+
+	if len(t.ops) == 0 {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// returns true if transformation results in multiple values
+func (t *CompiledTransform) splits() bool {
+	split := false
+	if t != nil {
+		for _, op := range t.ops {
+			switch op.name {
+			case "split":
+				split = true
+			case "join":
+				split = false
+			}
+		}
+	}
+	return split
+}
+
+// Applies transformations to value; returns list of values since "split" makes several of one
+func (t *CompiledTransform) Apply(data []byte) [][]byte {
+	if t == nil {
+		return [][]byte{data}
+	}
+
+	values := []string{string(data)}
+	for _, op := range t.ops {
+		switch op.name {
+		case "split":
+			res := make([]string, 0)
+			for _, v := range values {
+				res = append(res, strings.Split(v, op.args[0])...)
+			}
+			values = res
+		case "join":
+			values = []string{strings.Join(values, op.args[0])}
+		default:
+			for i, v := range values {
+				values[i] = op.apply(v)
+			}
+		}
+	}
+
+	res := make([][]byte, len(values))
+	for i, v := range values {
+		res[i] = []byte(v)
+	}
+	return res
+}
+
+// applies single value operation
+func (op *transformOp) apply(s string) string {
+	switch op.name {
+	case "trim":
+		return strings.TrimSpace(s)
+	case "collapse_ws":
+		return strings.Join(strings.Fields(s), " ")
+	case "lower":
+		return strings.ToLower(s)
+	case "upper":
+		return strings.ToUpper(s)
+	case "replace":
+		return strings.Replace(s, op.args[0], op.args[1], -1)
+	case "html_unescape":
+		return html.UnescapeString(s)
+	case "strip_tags":
+		return stripTags(s)
+	case "truncate":
+		if utf8.RuneCountInString(s) > op.n {
+			return string([]rune(s)[:op.n])
+		}
+	case "default":
+		if s == "" {
+			return op.args[0]
+		}
+	}
+	return s
+}
+
+// text of HTML fragment, entities are kept as they are
+func stripTags(s string) string {
+	var buf bytes.Buffer
+	z := nethtml.NewTokenizer(strings.NewReader(s))
+	for {
+		switch z.Next() {
+		case nethtml.ErrorToken:
+			return buf.String()
+		case nethtml.TextToken:
+			buf.Write(z.Raw())
+		}
+	}
+}
+
+// splits line by spaces; quoted arguments may contain spaces
+func splitArgs(line string) ([]string, error) {
+	res := make([]string, 0)
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			// Go string literal
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, errors.New("Unterminated string")
+			}
+			s, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, s)
+			i = end + 1
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("Unterminated string")
+			}
+			res = append(res, line[i+1:i+1+end])
+			i += end + 2
+		default:
+			end := strings.IndexAny(line[i:], " \t")
+			if end < 0 {
+				end = len(line) - i
+			}
+			res = append(res, line[i:i+end])
+			i += end
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,84 @@
+// patterns
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/antchfx/xquery/html"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransform(t *testing.T) {
+	var cases = []struct {
+		transform string
+		data      string
+		result    []string
+	}{
+		{"trim", "  Go developer \n", []string{"Go developer"}},
+		{"collapse_ws", " Senior \n\t Go   developer ", []string{"Senior Go developer"}},
+		{"lower\nreplace \" \" \"-\"", "Go Developer", []string{"go-developer"}},
+		{"upper", "remote", []string{"REMOTE"}},
+		{"split ,\ntrim", "go, sql ,docker", []string{"go", "sql", "docker"}},
+		{"split ','\ntrim\njoin \" | \"", "go, sql", []string{"go | sql"}},
+		{"html_unescape", "Q&amp;A &lt;3", []string{"Q&A <3"}},
+		{"strip_tags\ncollapse_ws", "<p>Write <b>Go</b> &amp; SQL</p><br/>", []string{"Write Go &amp; SQL"}},
+		{"truncate 5", "Привіт, світ", []string{"Приві"}},
+		{"trim\ndefault 'n/a'", "   ", []string{"n/a"}},
+		{"default \"n/a\"", "value", []string{"value"}},
+		{"replace \"\\n\" \" \"", "a\nb", []string{"a b"}},
+	}
+
+	for _, c := range cases {
+		tr, err := CompileTransform(c.transform)
+		if assert.NoError(t, err, c.transform) {
+			res := make([]string, 0)
+			for _, v := range tr.Apply([]byte(c.data)) {
+				res = append(res, string(v))
+			}
+			assert.Equal(t, c.result, res, c.transform)
+		}
+	}
+
+	for _, bad := range []string{"capitalize", "replace a", "trim now", "truncate -1", "truncate x", "default \"n/a"} {
+		_, err := CompileTransform(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestRetrieve_transform(t *testing.T) {
+	n, _ := htmlquery.Parse(strings.NewReader(`<html><body>
+		<div class="title">
+			Senior   Go
+			Developer
+		</div>
+		<div class="tags">Go, SQL, , Docker</div>
+		<div class="salary">USD 1 200</div>
+	</body></html>`))
+
+	var cases = []struct {
+		field  *Field
+		result interface{}
+	}{
+		{&Field{Title: "Title", Type: "string", Path: "//div[@class='title']", Transform: "collapse_ws\nlower"}, "senior go developer"},
+		{&Field{Title: "Tags", Type: "[]string", Path: "//div[@class='tags']", Transform: "split ,\ntrim\nlower\ndefault none"}, []interface{}{"go", "sql", "none", "docker"}},
+		{&Field{Title: "Salary", Type: "int", Path: "//div[@class='salary']", Transform: "replace USD ''\nreplace ' ' ''"}, 1200},
+		{&Field{Title: "Html", Type: "html", Path: "//div[@class='tags']", Transform: "strip_tags\ntruncate 2"}, "Go"},
+		{&Field{Title: "Html", Type: "html", Multiple: true, Path: "//div[@class='tags']", Transform: "strip_tags\nsplit ,\ntrim\ndefault none"}, []interface{}{"Go", "SQL", "none", "Docker"}},
+	}
+	for _, c := range cases {
+		cf, err := c.field.Compile()
+		if assert.NoError(t, err, c.field.Title) {
+			assert.Equal(t, c.result, cf.Retrieve(n), c.field.Title)
+		}
+	}
+
+	for _, bad := range []*Field{
+		&Field{Title: "Tags", Type: "string", Path: "//div", Transform: "split ,"},
+		&Field{Title: "Item", Type: "struct", Path: "//div", Transform: "trim"},
+		&Field{Title: "Title", Type: "string", Path: "//div", Transform: "titlecase"},
+	} {
+		_, err := bad.Compile()
+		assert.Error(t, err, bad.Title)
+	}
+}
@@ -0,0 +1,118 @@
+// patterns
+package parser
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// query parameters dropped with "drop-tracking" normalization
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"yclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_ga":     true,
+	"_hsenc":  true,
+	"_hsmi":   true,
+}
+
+// URL normalization options for "url" type
+type urlOptions struct {
+	stripFragment bool
+	sortQuery     bool
+	dropTracking  bool
+}
+
+// Set URL normalization options: comma separated list of
+// "strip-fragment", "sort-query", "drop-tracking"
+func (t *Type) SetNormalize(options string) error {
+	if !t.isUrl {
+		if strings.TrimSpace(options) != "" {
+			return errors.New("Normalize is only allowed for url type")
+		}
+		return nil
+	}
+
+	t.urlOptions = urlOptions{}
+	for _, o := range strings.Split(options, ",") {
+		switch strings.TrimSpace(o) {
+		case "":
+		case "strip-fragment":
+			t.urlOptions.stripFragment = true
+		case "sort-query":
+			t.urlOptions.sortQuery = true
+		case "drop-tracking":
+			t.urlOptions.dropTracking = true
+		default:
+			return errors.New("Unrecognized normalize option " + o)
+		}
+	}
+	return nil
+}
+
+// Resolves URL found in document against request URL and <base href>, then normalizes it
+func (t *Type) resolveUrl(ctx *Context, data []byte) (interface{}, error) {
+	s := strings.TrimSpace(string(data))
+	if s == "" {
+		return nil, errors.New("Empty URL")
+	}
+
+	ref, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := ctx.baseUrl()
+	if err != nil {
+		return nil, err
+	}
+	if base != nil {
+		ref = base.ResolveReference(ref)
+	}
+
+	if t.urlOptions.stripFragment {
+		ref.Fragment = ""
+		ref.RawFragment = ""
+	}
+
+	if t.urlOptions.dropTracking || t.urlOptions.sortQuery {
+		query := ref.Query()
+		if t.urlOptions.dropTracking {
+			for key := range query {
+				if trackingParams[key] || strings.HasPrefix(key, "utm_") {
+					query.Del(key)
+				}
+			}
+		}
+		ref.RawQuery = encodeQuery(query, ref.RawQuery, t.urlOptions.sortQuery)
+	}
+
+	return ref.String(), nil
+}
+
+// encodes query; keeps original parameters order unless sorting is required
+func encodeQuery(query url.Values, raw string, sorted bool) string {
+	if sorted {
+		// url.Values.Encode sorts by key
+		return query.Encode()
+	}
+
+	parts := make([]string, 0)
+	for _, part := range strings.Split(raw, "&") {
+		key := part
+		if i := strings.Index(part, "="); i >= 0 {
+			key = part[:i]
+		}
+		if key, err := url.QueryUnescape(key); err == nil {
+			if _, ok := query[key]; ok {
+				parts = append(parts, part)
+			}
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,106 @@
+// patterns
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/antchfx/xquery/html"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveUrl(t *testing.T) {
+	ctx := &Context{URL: "https://example.com/jobs/list?page=2"}
+
+	var cases = []struct {
+		normalize string
+		in        string
+		out       string
+	}{
+		{"", "/jobs/123", "https://example.com/jobs/123"},
+		{"", "item?id=42", "https://example.com/jobs/item?id=42"},
+		{"", "//cdn.example.com/logo.png", "https://cdn.example.com/logo.png"},
+		{"", "http://other.org/a#top", "http://other.org/a#top"},
+		{"strip-fragment", "/a#top", "https://example.com/a"},
+		{"sort-query", "/a?b=2&a=1", "https://example.com/a?a=1&b=2"},
+		{"drop-tracking", "/a?z=1&utm_source=x&a=2&fbclid=y", "https://example.com/a?z=1&a=2"},
+	}
+
+	for _, c := range cases {
+		tp, _ := CompileType("url")
+		assert.NoError(t, tp.SetNormalize(c.normalize))
+		val, err := tp.resolveUrl(ctx, []byte(c.in))
+		if assert.NoError(t, err, c.in) {
+			assert.Equal(t, c.out, val, c.in)
+		}
+	}
+
+	tp, _ := CompileType("url")
+	assert.Error(t, tp.SetNormalize("strip-everything"))
+}
+
+func TestApplyHtml_baseHref(t *testing.T) {
+	f := &Field{
+		Title: "Links",
+		Type:  "[]url",
+		Path:  "//a/@href",
+	}
+	cf, err := f.Compile()
+	assert.NoError(t, err)
+	m := &CompiledMap{mime: "html", field: cf, url: &CompiledRegexRules{}}
+
+	n, _ := htmlquery.Parse(strings.NewReader(`<html>
+		<head><base href="/static/"></head>
+		<body><a href="a.html">A</a><a href="/b.html">B</a></body>
+	</html>`))
+	assert.Equal(t, map[string]interface{}{
+		"Links": []interface{}{"https://example.com/static/a.html", "https://example.com/b.html"},
+	}, m.ApplyHtml("https://example.com/jobs/", n))
+}
+
+var capturePatternStr = `
+<Pattern mime="html">
+	<URL>
+		<Include><![CDATA[^https://example\.com/(?P<Category>[a-z]+)/jobs/(?P<JobId>\d+)]]></Include>
+	</URL>
+	<Field title="Job" type="struct">
+		<Path>//body</Path>
+		<Field title="JobId" type="int" capture="JobId"/>
+		<Field title="Category" type="string" capture="Category">
+			<Transform>upper</Transform>
+		</Field>
+		<Field title="Title" type="string">
+			<Path>//h1</Path>
+		</Field>
+	</Field>
+</Pattern>
+`
+
+func TestApply_captures(t *testing.T) {
+	p := NewPatterns(nil)
+	assert.NoError(t, p.LoadXml(p.Tree, []byte(capturePatternStr), "capture.xml"))
+
+	data, err := p.Apply("https://example.com/design/jobs/123", strings.NewReader(`<html><body><h1>Designer</h1></body></html>`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"capture.xml": map[string]interface{}{
+			"Job": map[string]interface{}{
+				"JobId":    123,
+				"Category": "DESIGN",
+				"Title":    "Designer",
+			},
+		},
+	}, data)
+
+	// capture must refer to URL named group
+	bad := strings.Replace(capturePatternStr, `capture="JobId"`, `capture="Id"`, 1)
+	assert.Error(t, p.LoadXml(p.Tree, []byte(bad), "bad.xml"))
+
+	for _, f := range []*Field{
+		&Field{Title: "JobId", Type: "int", Capture: "JobId", Path: "//h1"},
+		&Field{Title: "JobId", Type: "struct", Capture: "JobId"},
+	} {
+		_, err := f.Compile()
+		assert.Error(t, err)
+	}
+}
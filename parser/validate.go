@@ -0,0 +1,217 @@
+// patterns
+package parser
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Constraints checked after conversion to field type:
+//
+//	min="1" max="1000000"           numbers
+//	minlength="3" maxlength="200"   number of characters of strings
+//	enum="full-time,part-time"      allowed values, comma separated
+//	match="^[A-Z]"                  regex value must match
+//	format="email"                  email, url (absolute http or https one) or uuid
+//
+// values violating constraints are handled according to oninvalid attribute:
+//
+//	drop      (default) value is dropped as if it wasn't found, so default value and required level apply
+//	null      value is replaced with null, struct is kept even if field is required
+//	report    value is kept
+//
+// every violation is reported with DiagInvalid diagnostic
+const (
+	InvalidDrop   = "drop"
+	InvalidNull   = "null"
+	InvalidReport = "report"
+)
+
+type constraints struct {
+	min, max             *float64
+	minLength, maxLength int
+	enum                 []string
+	match                *regexp.Regexp
+	format               string
+	onInvalid            string
+}
+
+var formatRegexps = map[string]*regexp.Regexp{
+	"email": regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`),
+	"uuid":  regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`),
+}
+
+func (f *Field) compileConstraints(c *CompiledField) error {
+	fail := func(msg string) error {
+		return errors.New("Failed to compile " + f.Title + ". " + msg)
+	}
+	base := c.dataType.base()
+	numeric := false
+	switch base.kind {
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Float64:
+		numeric = true
+	}
+
+	res := &constraints{}
+	for _, bound := range []struct {
+		name, value string
+		dst         **float64
+	}{{"min", f.Min, &res.min}, {"max", f.Max, &res.max}} {
+		if bound.value == "" {
+			continue
+		}
+		if !numeric {
+			return fail("Constraint " + bound.name + " is only supported by number types")
+		}
+		n, err := strconv.ParseFloat(bound.value, 64)
+		if err != nil {
+			return fail("Constraint " + bound.name + " isn't a number: " + bound.value)
+		}
+		*bound.dst = &n
+	}
+
+	if f.MinLength != 0 || f.MaxLength != 0 {
+		if base.kind != reflect.String {
+			return fail("Constraints minlength and maxlength are only supported by string types")
+		}
+		if f.MinLength < 0 || f.MaxLength < 0 || f.MaxLength != 0 && f.MaxLength < f.MinLength {
+			return fail("Wrong minlength or maxlength")
+		}
+	}
+	res.minLength, res.maxLength = f.MinLength, f.MaxLength
+
+	if f.Enum != "" {
+		for _, v := range strings.Split(f.Enum, ",") {
+			res.enum = append(res.enum, strings.TrimSpace(v))
+		}
+	}
+
+	if f.Match != "" {
+		var err error
+		res.match, err = regexp.Compile(f.Match)
+		if err != nil {
+			return fail("Constraint match error: " + err.Error())
+		}
+	}
+
+	switch f.Format {
+	case "", "email", "url", "uuid":
+		res.format = f.Format
+	default:
+		return fail("Unrecognized format " + f.Format)
+	}
+
+	switch f.OnInvalid {
+	case "", InvalidDrop, InvalidNull, InvalidReport:
+		res.onInvalid = f.OnInvalid
+	default:
+		return fail("Unrecognized oninvalid policy " + f.OnInvalid)
+	}
+
+	if res.min == nil && res.max == nil && res.minLength == 0 && res.maxLength == 0 &&
+		res.enum == nil && res.match == nil && res.format == "" {
+		if f.OnInvalid != "" {
+			return fail("Oninvalid policy is set, but there are no constraints")
+		}
+		return nil
+	}
+	if base.kind == reflect.Struct || base.kind == reflect.Map {
+		return fail("Constraints aren't supported for " + c.dataType.String() + " type")
+	}
+
+	// default value must comply to constraints as well
+	if f.Default != "" && !base.isUrl {
+		val, _ := base.Convert([]byte(f.Default))
+		if reason := res.check(val); reason != "" {
+			return fail("Default " + reason)
+		}
+	}
+	c.constraints = res
+	return nil
+}
+
+// returns reason value violates constraints; empty if it doesn't
+func (c *constraints) check(val interface{}) string {
+	if c == nil {
+		return ""
+	}
+	text := exprText(val)
+
+	if n, ok := exprNumber(val); ok {
+		if c.min != nil && n < *c.min {
+			return "value " + text + " is less than min " + strconv.FormatFloat(*c.min, 'f', -1, 64)
+		}
+		if c.max != nil && n > *c.max {
+			return "value " + text + " is greater than max " + strconv.FormatFloat(*c.max, 'f', -1, 64)
+		}
+	}
+
+	if length := utf8.RuneCountInString(text); c.minLength > 0 && length < c.minLength {
+		return "value is shorter than minlength " + strconv.Itoa(c.minLength)
+	} else if c.maxLength > 0 && length > c.maxLength {
+		return "value is longer than maxlength " + strconv.Itoa(c.maxLength)
+	}
+
+	if c.enum != nil && !containsString(c.enum, text) {
+		return "value isn't one of enum " + strings.Join(c.enum, ",")
+	}
+
+	if c.match != nil && !c.match.MatchString(text) {
+		return "value doesn't match " + c.match.String()
+	}
+
+	switch c.format {
+	case "url":
+		u, err := url.Parse(text)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "value isn't http(s) URL"
+		}
+	case "":
+	default:
+		if !formatRegexps[c.format].MatchString(text) {
+			return "value isn't " + c.format
+		}
+	}
+	return ""
+}
+
+// checks converted value; returns nil if value is dropped or nulled
+func (f *CompiledField) validate(ctx *Context, val interface{}, data []byte, report *Report) interface{} {
+	reason := f.constraints.check(val)
+	if reason == "" {
+		return val
+	}
+
+	switch f.constraints.onInvalid {
+	case InvalidReport:
+		report.add(DiagInvalid, reason+", value kept", data)
+		return val
+	case InvalidNull:
+		report.add(DiagInvalid, reason+", value nulled", data)
+		ctx.nullify()
+		return nil
+	}
+	report.add(DiagInvalid, reason+", value dropped", data)
+	return nil
+}
+
+// returns copy of context tracking whether value of the field being retrieved was nulled
+func (ctx *Context) withNulled(nulled *bool) *Context {
+	c := &Context{}
+	if ctx != nil {
+		*c = *ctx
+	}
+	c.nulled = nulled
+	return c
+}
+
+func (ctx *Context) nullify() {
+	if ctx != nil && ctx.nulled != nil {
+		*ctx.nulled = true
+	}
+}
@@ -0,0 +1,86 @@
+// patterns
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var validatePatternStr = `
+<Pattern mime="html">
+	<Field title="Job" type="[]struct">
+		<Path>//li</Path>
+		<Field title="Title" type="string" minlength="3" maxlength="40" match="^[A-Z]">
+			<Path>.//h2</Path>
+		</Field>
+		<Field title="Price" type="float64" min="1" oninvalid="null">
+			<Path>.//span[@class='price']</Path>
+		</Field>
+		<Field title="Kind" type="string" enum="full-time, part-time" default="full-time">
+			<Path>.//span[@class='kind']</Path>
+		</Field>
+		<Field title="Link" type="string" format="url" oninvalid="report">
+			<Path>.//a/@href</Path>
+		</Field>
+		<Field title="Contacts" type="[]string" format="email">
+			<Path>.//span[@class='email']</Path>
+		</Field>
+	</Field>
+</Pattern>
+`
+
+func TestRetrieve_validate(t *testing.T) {
+	p := NewPatterns(nil)
+	assert.NoError(t, p.LoadXml(p.Tree, []byte(validatePatternStr), "validate.xml"))
+
+	data, reports, err := p.ApplyWithReport("", strings.NewReader(`<html><body><ul>
+		<li><h2>Go developer</h2><span class="price">0</span><span class="kind">contract</span>
+			<a href="javascript:void(0)">Apply</a>
+			<span class="email">jobs@example.com</span><span class="email">n/a</span></li>
+		<li><h2>We use cookies to improve your experience, please accept them</h2></li>
+	</ul></body></html>`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"validate.xml": map[string]interface{}{
+			"Job": []interface{}{
+				map[string]interface{}{
+					"Title":    "Go developer",
+					"Price":    nil,
+					"Kind":     "full-time",
+					"Link":     "javascript:void(0)",
+					"Contacts": []interface{}{"jobs@example.com"},
+				},
+			},
+		},
+	}, data)
+
+	report := reports["validate.xml"]
+	assert.Equal(t, "value is longer than maxlength 40, value dropped", report.child("Title").Diagnostics[0].Message)
+	assert.Equal(t, "value 0 is less than min 1, value nulled", report.child("Price").Diagnostics[0].Message)
+	assert.Equal(t, "value isn't one of enum full-time,part-time, value dropped", report.child("Kind").Diagnostics[0].Message)
+	assert.Equal(t, "value isn't http(s) URL, value kept", report.child("Link").Diagnostics[0].Message)
+	assert.Equal(t, DiagInvalid, report.child("Contacts").Diagnostics[0].Kind)
+
+	for _, bad := range []*Field{
+		&Field{Title: "A", Type: "string", Path: "//a", Min: "1"},
+		&Field{Title: "A", Type: "time", Path: "//a", Min: "1"},
+		&Field{Title: "A", Type: "int", Path: "//a", Max: "many"},
+		&Field{Title: "A", Type: "int", Path: "//a", MinLength: 1},
+		&Field{Title: "A", Type: "string", Path: "//a", MinLength: 5, MaxLength: 2},
+		&Field{Title: "A", Type: "string", Path: "//a", Match: "("},
+		&Field{Title: "A", Type: "string", Path: "//a", Format: "phone"},
+		&Field{Title: "A", Type: "string", Path: "//a", OnInvalid: "null"},
+		&Field{Title: "A", Type: "string", Path: "//a", Format: "url", OnInvalid: "ignore"},
+	} {
+		_, err := bad.Compile()
+		assert.Error(t, err)
+	}
+
+	// default values are checked too
+	_, err = (&Field{Title: "A", Type: "string", Path: "//a", Enum: "a,b", Default: "a"}).Compile()
+	assert.NoError(t, err)
+	_, err = (&Field{Title: "A", Type: "string", Path: "//a", Enum: "a,b", Default: "c"}).Compile()
+	assert.EqualError(t, err, "Failed to compile A. Default value isn't one of enum a,b")
+}
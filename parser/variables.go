@@ -0,0 +1,259 @@
+// patterns
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antchfx/xpath"
+)
+
+// XPath variables: field Path may reference runtime values, like:
+//
+//	//tr[@data-id=$JobId]/td[@class='title']
+//
+// available variables are:
+//
+//	$url, $host    request URL and its host
+//	$JobId         named group of URL Include regex
+//	$Title         value of sibling field declared above within the same (or enclosing) struct
+//
+// antchfx/xpath doesn't bind variables on evaluation, so they are substituted with
+// string literals and expression is compiled for every evaluation
+
+// compiles field Path expressions; expressions with variables are kept as templates
+// (with nil compiled expression) to be bound on evaluation
+func cdataToFieldPaths(data string) ([]*xpath.Expr, []string, error) {
+	paths := make([]*xpath.Expr, 0)
+	var templates []string
+	for _, x := range lineSplit.Split(data, -1) {
+		x := strings.TrimSpace(x)
+		if len(x) == 0 {
+			continue
+		}
+
+		expr, err := lineToXpath(x)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		names, err := xpathVariables(expr)
+		if err != nil {
+			return nil, nil, errors.New(err.Error() + "\n Path: " + x)
+		}
+		if len(names) == 0 {
+			query, err := xpath.Compile(expr)
+			if err != nil {
+				return nil, nil, errors.New(err.Error() + "\n Path: " + x)
+			}
+			paths = append(paths, query)
+			templates = append(templates, "")
+			continue
+		}
+
+		// syntax is checked with empty values
+		empty := make(map[string]string)
+		for _, name := range names {
+			empty[name] = ""
+		}
+		probe, _ := bindVariables(expr, empty)
+		if _, err := xpath.Compile(probe); err != nil {
+			return nil, nil, errors.New(err.Error() + "\n Path: " + x)
+		}
+		paths = append(paths, nil)
+		templates = append(templates, expr)
+	}
+
+	for _, t := range templates {
+		if t != "" {
+			return paths, templates, nil
+		}
+	}
+	return paths, nil, nil
+}
+
+// names of variables referenced by XPath expression; string literals are skipped
+func xpathVariables(expr string) ([]string, error) {
+	var buf strings.Builder
+	names := make([]string, 0)
+	err := scanVariables(&buf, expr, func(name string) string {
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+		return ""
+	})
+	return names, err
+}
+
+// replaces variable references of XPath expression with string literals
+func bindVariables(expr string, values map[string]string) (string, error) {
+	var buf strings.Builder
+	missing := ""
+	err := scanVariables(&buf, expr, func(name string) string {
+		val, ok := values[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return xpathLiteral(val)
+	})
+	if err != nil {
+		return "", err
+	}
+	if missing != "" {
+		return "", errors.New("undefined variable $" + missing)
+	}
+	return buf.String(), nil
+}
+
+// copies expression to buf with every "$name" outside of string literals replaced
+func scanVariables(buf *strings.Builder, expr string, replace func(name string) string) error {
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return errors.New("Unterminated string")
+			}
+			buf.WriteString(expr[i : i+end+2])
+			i += end + 2
+		case c == '$':
+			end := i + 1
+			for end < len(expr) && isWordChar(expr[end]) && expr[end] != '$' {
+				end++
+			}
+			if end == i+1 {
+				return errors.New("Variable name expected")
+			}
+			buf.WriteString(replace(expr[i+1 : end]))
+			i = end
+		default:
+			buf.WriteByte(c)
+			i++
+		}
+	}
+	return nil
+}
+
+// Path expressions of field with variables bound to context values;
+// expressions with undefined variables are skipped
+func (f *CompiledField) paths(ctx *Context, report *Report) []*xpath.Expr {
+	if f.templates == nil {
+		return f.path
+	}
+	res := make([]*xpath.Expr, 0, len(f.path))
+	for i, query := range f.path {
+		if f.templates[i] == "" {
+			res = append(res, query)
+			continue
+		}
+		expr, err := bindVariables(f.templates[i], ctx.variables())
+		if err == nil {
+			query, err = xpath.Compile(expr)
+		}
+		if err != nil {
+			report.add(DiagNoMatch, err.Error(), []byte(f.templates[i]))
+			continue
+		}
+		res = append(res, query)
+	}
+	return res
+}
+
+// checks every variable referenced by Path or Expr is retrieved before it;
+// "declared" are URL named groups and previous siblings of field and its parents
+func (f *CompiledField) checkVariables(declared []string) error {
+	for _, t := range f.templates {
+		names, _ := xpathVariables(t)
+		for _, name := range names {
+			if name != "url" && name != "host" && !containsString(declared, name) {
+				return errors.New("Field " + f.title + " Path references undefined variable $" + name)
+			}
+		}
+	}
+
+	// paths see siblings declared above, computed fields are evaluated last and see
+	// every sibling retrieved before them
+	for _, fields := range f.fieldSets() {
+		scope := append([]string{}, declared...)
+		for _, child := range computedLast(fields) {
+			if child.expr != nil {
+				for _, name := range child.expr.Names() {
+					if name != "url" && name != "host" && !containsString(scope, name) {
+						return errors.New("Field " + child.title + " Expr references undefined value " + name)
+					}
+				}
+			} else if err := child.checkVariables(scope); err != nil {
+				return err
+			}
+			scope = append(scope, child.title)
+		}
+	}
+	return nil
+}
+
+// text of scalar field value; lists and structs can't be variables
+func variableValue(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case time.Time:
+		return v.Format(time.RFC3339), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+	switch reflect.ValueOf(val).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Struct, reflect.Ptr:
+		return "", false
+	}
+	return fmt.Sprint(val), true
+}
+
+// all variables available within context
+func (ctx *Context) variables() map[string]string {
+	res := make(map[string]string)
+	if ctx == nil {
+		return res
+	}
+	res["url"] = ctx.URL
+	res["host"] = ""
+	if u, err := url.Parse(ctx.URL); err == nil {
+		res["host"] = u.Host
+	}
+	for k, v := range ctx.Captures {
+		res[k] = v
+	}
+	for k, v := range ctx.vars {
+		res[k] = v
+	}
+	return res
+}
+
+// returns copy of context with own set of sibling field variables
+func (ctx *Context) withVariables() *Context {
+	c := &Context{}
+	if ctx != nil {
+		*c = *ctx
+	}
+	c.vars = make(map[string]string)
+	if ctx != nil {
+		for k, v := range ctx.vars {
+			c.vars[k] = v
+		}
+	}
+	return c
+}
+
+// makes field value available as variable to fields retrieved after it
+func (ctx *Context) bind(name string, val interface{}) {
+	if s, ok := variableValue(val); ok {
+		ctx.vars[name] = s
+	}
+}
@@ -0,0 +1,72 @@
+// patterns
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var variablesPatternStr = `
+<Pattern mime="html">
+	<URL>
+		<Include><![CDATA[^https://example\.com/jobs/(?P<JobId>\d+)]]></Include>
+	</URL>
+	<Field title="Job" type="struct">
+		<Path>//body</Path>
+		<Field title="Title" type="string">
+			<Path>//tr[@data-id=$JobId]/td[@class='title']</Path>
+		</Field>
+		<Field title="Company" type="string">
+			<Path>//a[@data-host=$host and @data-job=$Title]</Path>
+		</Field>
+		<Field title="Label" type="string">
+			<Path>//span[@title='$Title']</Path>
+		</Field>
+	</Field>
+</Pattern>
+`
+
+var variablesHtml = `<html><body><table>
+	<tr data-id="1"><td class="title">Designer</td></tr>
+	<tr data-id="12"><td class="title">Go "gopher" developer's job</td></tr>
+</table>
+<a data-host="example.com" data-job="Designer">Other</a>
+<a data-host="example.com" data-job="Go &quot;gopher&quot; developer's job">Acme</a>
+<span title="$Title">Literal</span>
+</body></html>`
+
+func TestApply_variables(t *testing.T) {
+	p := NewPatterns(nil)
+	assert.NoError(t, p.LoadXml(p.Tree, []byte(variablesPatternStr), "variables.xml"))
+
+	data, err := p.Apply("https://example.com/jobs/12", strings.NewReader(variablesHtml))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"variables.xml": map[string]interface{}{
+			"Job": map[string]interface{}{
+				"Title":   `Go "gopher" developer's job`,
+				"Company": "Acme",
+				"Label":   "Literal",
+			},
+		},
+	}, data)
+
+	// variables must be declared above
+	bad := strings.Replace(variablesPatternStr, "$Title]", "$Salary]", 1)
+	assert.Error(t, p.LoadXml(p.Tree, []byte(bad), "bad.xml"))
+}
+
+func TestBindVariables(t *testing.T) {
+	expr, err := bindVariables(`//a[@id=$id and @class="$id"]/text()[contains(., $q)]`, map[string]string{"id": "1", "q": `it's "q"`})
+	assert.NoError(t, err)
+	assert.Equal(t, `//a[@id='1' and @class="$id"]/text()[contains(., concat('it', "'", 's "q"'))]`, expr)
+
+	_, err = bindVariables(`//a[@id=$id]`, nil)
+	assert.EqualError(t, err, "undefined variable $id")
+
+	names, err := xpathVariables(`//a[@id=$id][@href=$url][@title=$id]`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "url"}, names)
+}
@@ -0,0 +1,166 @@
+// patterns
+package parser
+
+import (
+	"reflect"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+// Retrieves field from XML document (RSS, Atom etc.) node
+func (f *CompiledField) retrieveXml(ctx *Context, root *xmlquery.Node, report *Report) (result interface{}) {
+	// element type of slices, type itself otherwise
+	dataType := f.dataType.base()
+
+	// "[][]string" like types group values by node found
+	nested := f.dataType.depth() > 1
+
+	if f.capture != "" {
+		return f.retrieveCapture(ctx, report)
+	}
+
+	if f.expr != nil {
+		return f.retrieveComputed(ctx, nil, report)
+	}
+
+	if dataType.kind != reflect.Struct || f.grouped {
+		// check every Path provided
+		for _, query := range f.paths(ctx, report) {
+			iter := query.Select(xmlquery.CreateXPathNavigator(root))
+			hits := 0
+
+			if f.multiple {
+				res := make([]interface{}, 0)
+				for iter.MoveNext() {
+					hits++
+					if dataType.isHtml {
+						// values found within current node only
+						group := make([]interface{}, 0)
+						for _, val := range f.xmlValues(ctx, xmlNode(iter), report) {
+							if nested {
+								group = f.appendValue(group, val)
+							} else {
+								res = f.appendValue(res, val)
+							}
+						}
+						if nested && len(group) > 0 {
+							res = append(res, group)
+						}
+						continue
+					}
+
+					bts := []byte(iter.Current().Value())
+					if !f.testData(bts, report) {
+						continue
+					}
+
+					// values found within current node only
+					group := make([]interface{}, 0)
+					for _, val := range f.matchValues(ctx, bts, report) {
+						if nested {
+							group = f.appendValue(group, val)
+						} else {
+							res = f.appendValue(res, val)
+						}
+					}
+					if nested && len(group) > 0 {
+						res = append(res, group)
+					}
+				}
+				result = interface{}(res)
+			} else if iter.MoveNext() {
+				hits++
+				if dataType.isHtml {
+					result = f.xmlValue(ctx, xmlNode(iter), report)
+				} else {
+					val := []byte(iter.Current().Value())
+					if f.testData(val, report) {
+						result = f.matchValue(ctx, val, report)
+					}
+				}
+			}
+			report.hit(query.String(), hits)
+
+			// exit if at least 1 value found for Path
+			if result != nil {
+				report.matched(query.String(), countValues(result))
+				return result
+			}
+		}
+	} else {
+		// only one path available works for struct
+		paths := f.paths(ctx, report)
+		if len(paths) == 0 {
+			return nil
+		}
+		query := paths[0]
+		iter := query.Select(xmlquery.CreateXPathNavigator(root))
+		hits := 0
+
+		if f.multiple {
+			res := make([]interface{}, 0)
+			for iter.MoveNext() {
+				hits++
+				if val := f.retrieveXmlStruct(ctx, xmlNode(iter), report); val != nil {
+					res = append(res, val)
+				}
+			}
+			result = res
+			report.matched(query.String(), len(res))
+		} else if iter.MoveNext() {
+			hits++
+			if val := f.retrieveXmlStruct(ctx, xmlNode(iter), report); val != nil {
+				result = val
+				report.matched(query.String(), 1)
+			}
+		}
+
+		report.hit(query.String(), hits)
+		if hits == 0 {
+			report.add(DiagNoMatch, "path matched no nodes", nil)
+		}
+	}
+
+	return result
+}
+
+func (f *CompiledField) retrieveXmlStruct(ctx *Context, node *xmlquery.Node, report *Report) map[string]interface{} {
+	v, ok := f.variant(xmlquery.CreateXPathNavigator(node), func() []byte { return []byte(node.InnerText()) }, report)
+	if !ok {
+		return nil
+	}
+	return f.collectStruct(ctx, report, v, func(ctx *Context, child *CompiledField, childReport *Report) interface{} {
+		return child.retrieveXml(ctx, node, childReport)
+	})
+}
+
+// outputs node markup ("html" type) and converts it to field type; Transform could split it to several values
+func (f *CompiledField) xmlValues(ctx *Context, node *xmlquery.Node, report *Report) []interface{} {
+	// test include/exclude
+	if ok, reason := f.xdata.Check(xmlquery.CreateXPathNavigator(node)); !ok {
+		report.add(DiagRejected, reason, []byte(node.InnerText()))
+		return nil
+	}
+
+	// clean off unnecessary nodes
+	node = f.xdata.CleanXml(node)
+	if node == nil {
+		return nil
+	}
+
+	return f.transformValues(ctx, []byte(node.OutputXML(true)), report)
+}
+
+// same as xmlValues for fields of single value
+func (f *CompiledField) xmlValue(ctx *Context, node *xmlquery.Node, report *Report) interface{} {
+	if vals := f.xmlValues(ctx, node, report); len(vals) > 0 {
+		return vals[0]
+	}
+	return nil
+}
+
+// node iterator points at
+func xmlNode(iter *xpath.NodeIterator) *xmlquery.Node {
+	return iter.Current().(*xmlquery.NodeNavigator).Current()
+}
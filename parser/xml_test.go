@@ -0,0 +1,172 @@
+// patterns
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var rssDoc = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+	<channel>
+		<title>FOSS Jobs</title>
+		<item>
+			<title>Go developer</title>
+			<link>https://www.fossjobs.net/job/1</link>
+			<dc:creator>Acme</dc:creator>
+			<description><![CDATA[<p>Write <b>Go</b> &amp; SQL</p>]]></description>
+			<pubDate>Mon, 05 Mar 2018 10:00:00 +0000</pubDate>
+		</item>
+		<item>
+			<title>Designer</title>
+			<link>https://www.fossjobs.net/job/2</link>
+			<dc:creator>Initech</dc:creator>
+			<description><![CDATA[Draw things]]></description>
+			<pubDate>Tue, 06 Mar 2018 10:00:00 +0000</pubDate>
+		</item>
+	</channel>
+</rss>`
+
+var rssPatternStr = `
+<Pattern mime="xml">
+	<URL>
+		<Include><![CDATA[
+			^https://www.fossjobs.net/rss
+		]]></Include>
+	</URL>
+	<Field title="Item" type="[]struct">
+		<Path>//channel/item</Path>
+		<Field title="Title" type="string">
+			<Path>title</Path>
+		</Field>
+		<Field title="Link" type="url">
+			<Path>link</Path>
+		</Field>
+		<Field title="Company" type="string">
+			<Path>dc:creator</Path>
+		</Field>
+		<Field title="Description" type="string">
+			<Path>description</Path>
+		</Field>
+		<Field title="Posted" type="time">
+			<Path>pubDate</Path>
+		</Field>
+	</Field>
+</Pattern>
+`
+
+var atomDoc = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Jobs</title>
+	<entry>
+		<title>Go developer</title>
+		<link href="/jobs/1"/>
+		<content type="html"><p>Go</p><p>Remote</p></content>
+	</entry>
+</feed>`
+
+func TestApplyXml(t *testing.T) {
+	pn := NewPatterns(nil)
+	assert.NoError(t, pn.LoadXml(pn.Tree, []byte(rssPatternStr), "rss.xml"))
+
+	data, err := pn.ApplyContext(&Context{URL: "https://www.fossjobs.net/rss", Mime: "xml"}, strings.NewReader(rssDoc))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"rss.xml": map[string]interface{}{
+			"Item": []interface{}{
+				map[string]interface{}{
+					"Title":       "Go developer",
+					"Link":        "https://www.fossjobs.net/job/1",
+					"Company":     "Acme",
+					"Description": "<p>Write <b>Go</b> &amp; SQL</p>",
+					"Posted":      "2018-03-05T10:00:00Z",
+				},
+				map[string]interface{}{
+					"Title":       "Designer",
+					"Link":        "https://www.fossjobs.net/job/2",
+					"Company":     "Initech",
+					"Description": "Draw things",
+					"Posted":      "2018-03-06T10:00:00Z",
+				},
+			},
+		},
+	}, data)
+}
+
+func TestRetrieveXml_atom(t *testing.T) {
+	f := &Map{
+		Mime: "xml",
+		Field: &Field{
+			Title: "Entry",
+			Type:  "[]struct",
+			Path:  "//feed/entry",
+			Field: []*Field{
+				&Field{Title: "Title", Type: "string", Path: "title"},
+				&Field{Title: "Link", Type: "url", Path: "link/@href"},
+				&Field{
+					Title: "Content",
+					Type:  "html",
+					Path:  "content",
+					XData: &XpathRules{Remove: "p[2]"},
+				},
+			},
+		},
+	}
+	m, err := f.Compile()
+	assert.NoError(t, err)
+
+	doc, err := ParseDocument("xml", strings.NewReader(atomDoc))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Entry": []interface{}{
+			map[string]interface{}{
+				"Title":   "Go developer",
+				"Link":    "https://example.com/jobs/1",
+				"Content": `<content type="html"><p>Go</p></content>`,
+			},
+		},
+	}, m.ApplyDocument(&Context{URL: "https://example.com/feed"}, doc))
+}
+
+func TestRetrieveXml_split(t *testing.T) {
+	f := &Map{
+		Mime:  "xml",
+		Field: &Field{Title: "Content", Type: "html", Multiple: true, Path: "//feed/entry/content", Transform: "strip_tags\nsplit R"},
+	}
+	m, err := f.Compile()
+	assert.NoError(t, err)
+
+	doc, err := ParseDocument("xml", strings.NewReader(atomDoc))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"Content": []interface{}{"Go", "emote"}},
+		m.ApplyDocument(&Context{URL: "https://example.com/feed"}, doc))
+}
+
+func TestRetrieveXml_removeOutside(t *testing.T) {
+	f := &Map{
+		Mime: "xml",
+		Field: &Field{
+			Title: "Entry",
+			Type:  "struct",
+			Path:  "//feed/entry",
+			Field: []*Field{
+				// nodes outside of content are left in document
+				&Field{Title: "Content", Type: "html", Path: "content", XData: &XpathRules{Remove: "../title"}},
+				&Field{Title: "Title", Type: "string", Path: "title"},
+			},
+		},
+	}
+	m, err := f.Compile()
+	assert.NoError(t, err)
+
+	doc, err := ParseDocument("xml", strings.NewReader(atomDoc))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Entry": map[string]interface{}{
+			"Content": `<content type="html"><p>Go</p><p>Remote</p></content>`,
+			"Title":   "Go developer",
+		},
+	}, m.ApplyDocument(&Context{URL: "https://example.com/feed"}, doc))
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	//"fmt"
 
+	"github.com/antchfx/xmlquery"
 	"github.com/antchfx/xpath"
 	"github.com/antchfx/xquery/html"
 	"golang.org/x/net/html"
@@ -14,6 +15,12 @@ type XpathRules struct {
 	Include string
 	Exclude string
 	Remove  string
+
+	// fragment rules are based on; see fragments.go
+	Use string `xml:"use,attr,omitempty"`
+
+	// fragment name (only in fragments file)
+	Name string `xml:"name,attr,omitempty"`
 }
 
 type CompiledXpathRules struct {
@@ -50,6 +57,12 @@ func (p *XpathRules) Compile() (*CompiledXpathRules, error) {
 
 // test if complies to defined rules
 func (p *CompiledXpathRules) Test(s xpath.NodeNavigator) bool {
+	ok, _ := p.Check(s)
+	return ok
+}
+
+// same as Test, but also returns the reason of rejection
+func (p *CompiledXpathRules) Check(s xpath.NodeNavigator) (bool, string) {
 	if p != nil {
 		// URL must apply to at least one "include" patern
 		count := len(p.Include)
@@ -63,17 +76,17 @@ func (p *CompiledXpathRules) Test(s xpath.NodeNavigator) bool {
 				}
 			}
 			if count == 0 {
-				return false
+				return false, "no Include xpath matched"
 			}
 		}
 
 		for _, r := range p.Exclude {
 			if r.Select(s).MoveNext() {
-				return false
+				return false, "Exclude xpath matched: " + r.String()
 			}
 		}
 	}
-	return true
+	return true, ""
 }
 
 func (p *CompiledXpathRules) Clean(s *html.Node) *html.Node {
@@ -100,6 +113,57 @@ func (p *CompiledXpathRules) Clean(s *html.Node) *html.Node {
 	return s
 }
 
+// same as Clean, but for XML documents
+func (p *CompiledXpathRules) CleanXml(s *xmlquery.Node) *xmlquery.Node {
+	var list []*xmlquery.Node
+	if p != nil {
+		for _, r := range p.Remove {
+			if r != nil {
+				iter := r.Select(xmlquery.CreateXPathNavigator(s))
+				for iter.MoveNext() {
+					list = append(list, xmlNode(iter))
+				}
+			}
+		}
+
+		for _, toRemove := range list {
+			// paths like "../title" or absolute ones could find nodes outside of root, only its descendants are removed
+			if isXmlDescendant(toRemove, s) {
+				removeXmlNode(toRemove)
+			}
+		}
+	}
+
+	return s
+}
+
+// true if "root" is one of ancestors of n
+func isXmlDescendant(n, root *xmlquery.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p == root {
+			return true
+		}
+	}
+	return false
+}
+
+func removeXmlNode(n *xmlquery.Node) {
+	if n.Parent == nil {
+		return
+	}
+	if n.PrevSibling != nil {
+		n.PrevSibling.NextSibling = n.NextSibling
+	} else {
+		n.Parent.FirstChild = n.NextSibling
+	}
+	if n.NextSibling != nil {
+		n.NextSibling.PrevSibling = n.PrevSibling
+	} else {
+		n.Parent.LastChild = n.PrevSibling
+	}
+	n.Parent, n.PrevSibling, n.NextSibling = nil, nil, nil
+}
+
 func iterateNodes(r *html.Node, cb func(n *html.Node)) {
 	for c := r.FirstChild; c != nil; c = c.NextSibling {
 		cb(c)
@@ -43,18 +43,31 @@ func main() {
 		*patternsDir = "patterns"
 	}
 	patterns := parser.NewPatterns(logger)
-	err := patterns.LoadTree(patterns.Tree, *patternsDir)
+	err := patterns.LoadTree(*patternsDir)
 	if err != nil {
 		logger.Panic(err)
 	}
 
-	interceptor := NewProxyInterceptor(func(header, body *bytes.Buffer) io.ReadCloser {
+	interceptor := NewProxyInterceptor(func(header, body *bytes.Buffer, respHeader http.Header) io.ReadCloser {
 		// proxy handler
 		url := string(regexp.MustCompile(`(GET|POST|PUT|HEAD|DELETE|OPTIONS)\s+(.+)\s+(HTTP)`).FindAllSubmatch(header.Bytes(), -1)[0][2])
-		node, err := patterns.Apply(url, body)
+
+		// response date is an anchor for relative dates, like "3 days ago"
+		ctx := &parser.Context{URL: url, Mime: parser.MimeType(respHeader.Get("Content-Type"))}
+		if date, err := http.ParseTime(respHeader.Get("Date")); err == nil {
+			ctx.Date = date
+		}
+
+		// documents no pattern is written for are passed through untouched
+		original := body.Bytes()
+		if !patterns.Tree.Applies(ctx) {
+			return ioutil.NopCloser(bytes.NewReader(original))
+		}
+
+		node, err := patterns.ApplyContext(ctx, bytes.NewReader(original))
 		if err != nil {
 			logger.Println("Error applying patterns: ", err.Error())
-			return nil
+			return ioutil.NopCloser(bytes.NewReader(original))
 		}
 
 		if node == nil {
@@ -63,6 +76,7 @@ func main() {
 		recognized, err := json.Marshal(&node)
 		if err != nil {
 			logger.Println("Error marshalling to JSON: ", err.Error())
+			return ioutil.NopCloser(bytes.NewReader(original))
 		}
 
 		return ioutil.NopCloser(bytes.NewBuffer(recognized))
@@ -71,7 +85,7 @@ func main() {
 		// controller
 		// used to reload patterns
 		patterns = parser.NewPatterns(logger)
-		err := patterns.LoadTree(patterns.Tree, *patternsDir)
+		err := patterns.LoadTree(*patternsDir)
 		if err != nil {
 			logger.Panic(err)
 		}
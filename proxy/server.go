@@ -10,17 +10,17 @@ import (
 	"net"
 	"net/http"
 	"regexp"
-	"strings"
 
 	"github.com/elazarl/goproxy"
+	"github.com/olesho/descry2/parser"
 )
 
 type ProxyInterceptor struct {
-	proxyHandler   func(header, body *bytes.Buffer) io.ReadCloser
+	proxyHandler   func(header, body *bytes.Buffer, respHeader http.Header) io.ReadCloser
 	controlHandler func(w http.ResponseWriter, r *http.Request)
 }
 
-func NewProxyInterceptor(h func(header, body *bytes.Buffer) io.ReadCloser, c func(w http.ResponseWriter, r *http.Request)) *ProxyInterceptor {
+func NewProxyInterceptor(h func(header, body *bytes.Buffer, respHeader http.Header) io.ReadCloser, c func(w http.ResponseWriter, r *http.Request)) *ProxyInterceptor {
 	return &ProxyInterceptor{h, c}
 }
 
@@ -67,7 +67,8 @@ func (i *ProxyInterceptor) Listen(port string, verbose bool) error {
 	proxy.OnResponse().DoFunc(func(r *http.Response, ctx *goproxy.ProxyCtx) *http.Response {
 		if ctx != nil {
 			if ctx.Resp != nil {
-				if strings.Contains(ctx.Resp.Header.Get("Content-Type"), "text/html") {
+				// only documents patterns could be applied to: HTML, JSON, XML
+				if parser.MimeType(ctx.Resp.Header.Get("Content-Type")) != "" {
 					defer ctx.Resp.Body.Close()
 					buf, err := ioutil.ReadAll(ctx.Resp.Body)
 					if err != nil {
@@ -83,7 +84,7 @@ func (i *ProxyInterceptor) Listen(port string, verbose bool) error {
 					io.Copy(bodyBuffer, rdr1)
 					rdr1.Close()
 
-					ctx.Resp.Body = i.proxyHandler(headerBuffer, bodyBuffer)
+					ctx.Resp.Body = i.proxyHandler(headerBuffer, bodyBuffer, ctx.Resp.Header)
 				}
 			}
 		}